@@ -0,0 +1,101 @@
+package lumigotracer
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/lumigo-io/go-tracer-beta/internal/awsparser"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+// awsCaptureSize the most bytes kept of both the start and the end of an
+// AWS request body for parsing, regardless of the body capture options
+const awsCaptureSize = 4 << 10
+
+// awsRequestCapture keeps the start and the end of an AWS request body,
+// the items of the writes larger than both are not hashed
+type awsRequestCapture struct {
+	mu   sync.Mutex
+	head []byte
+	tail []byte
+	size int64
+}
+
+func (c *awsRequestCapture) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size += int64(len(p))
+	written := len(p)
+	if remaining := awsCaptureSize - len(c.head); remaining > 0 {
+		if remaining > len(p) {
+			remaining = len(p)
+		}
+		c.head = append(c.head, p[:remaining]...)
+		p = p[remaining:]
+	}
+	if len(p) >= awsCaptureSize {
+		c.tail = append(c.tail[:0], p[len(p)-awsCaptureSize:]...)
+	} else if len(p) > 0 {
+		c.tail = append(c.tail, p...)
+		if extra := len(c.tail) - awsCaptureSize; extra > 0 {
+			c.tail = append(c.tail[:0], c.tail[extra:]...)
+		}
+	}
+	return written, nil
+}
+
+// body returns the captured body, whole if nothing was skipped
+func (c *awsRequestCapture) body() awsparser.Body {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if int64(len(c.head)+len(c.tail)) == c.size {
+		return awsparser.Body{Head: append(append([]byte{}, c.head...), c.tail...)}
+	}
+	return awsparser.Body{
+		Head: append([]byte{}, c.head...),
+		Tail: append([]byte{}, c.tail...),
+	}
+}
+
+// parseAWSRequest identifies the AWS service call of a request,
+// reqBody holds the request body captured for parsing and may be nil
+func parseAWSRequest(req *http.Request, reqBody *awsRequestCapture) awsparser.Info {
+	var body awsparser.Body
+	if reqBody != nil {
+		body = reqBody.body()
+	}
+	return awsparser.Parse(req, body)
+}
+
+// awsRequestAttributes the span attributes of an AWS service call
+func awsRequestAttributes(info awsparser.Info) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.RPCSystemKey.String("aws-api"),
+		semconv.RPCServiceKey.String(info.Service),
+	}
+	if info.Operation != "" {
+		attrs = append(attrs, semconv.RPCMethodKey.String(info.Operation))
+	}
+	if info.Region != "" {
+		attrs = append(attrs, attribute.String("aws.region", info.Region))
+	}
+	if info.ResourceName != "" {
+		attrs = append(attrs, attribute.String("aws.resource_name", info.ResourceName))
+	}
+	if info.Key != "" {
+		attrs = append(attrs, attribute.String("aws.s3.key", info.Key))
+	}
+	return attrs
+}
+
+// awsResponseAttributes the span attributes of the IDs returned by
+// messaging calls, scanner found the IDs of the response body and
+// may be nil
+func awsResponseAttributes(info awsparser.Info, header http.Header, scanner *awsparser.MessageIDScanner) []attribute.KeyValue {
+	ids := awsparser.ParseMessageIDs(info, header, scanner)
+	if len(ids) == 0 {
+		return nil
+	}
+	return []attribute.KeyValue{attribute.StringSlice("aws.message_ids", ids)}
+}
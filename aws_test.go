@@ -0,0 +1,207 @@
+package lumigotracer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/lumigo-io/go-tracer-beta/internal/awsparser"
+	"github.com/lumigo-io/go-tracer-beta/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTransportAWSAttributes(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := w.Write([]byte("{}")); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer ts.Close()
+
+	sr := tracetest.NewSpanRecorder()
+	tr := NewTransport(http.DefaultTransport)
+	tr.provider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	c := http.Client{Transport: tr}
+
+	for _, signed := range []bool{true, false} {
+		r, err := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(`{"TableName":"orders"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("X-Amz-Target", "DynamoDB_20120810.PutItem")
+		if signed {
+			r.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKID/20220101/us-east-1/dynamodb/aws4_request, SignedHeaders=host, Signature=abc")
+		}
+		res, err := c.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ioutil.ReadAll(res.Body); err != nil {
+			t.Fatal(err)
+		}
+		assert.NoError(t, res.Body.Close())
+	}
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 2)
+
+	attrs := testutil.AttributesMap(spans[0].Attributes())
+	assert.Equal(t, "aws-api", attrs["rpc.system"].AsString())
+	assert.Equal(t, "dynamodb", attrs["rpc.service"].AsString())
+	assert.Equal(t, "PutItem", attrs["rpc.method"].AsString())
+	assert.Equal(t, "us-east-1", attrs["aws.region"].AsString())
+	assert.Equal(t, "orders", attrs["aws.resource_name"].AsString())
+
+	// unsigned requests to non AWS hosts are plain HTTP calls
+	assert.NotContains(t, testutil.AttributesMap(spans[1].Attributes()), attribute.Key("rpc.system"))
+}
+
+func TestTransportAWSMessageIDs(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		if _, err := w.Write([]byte("<SendMessageResponse><SendMessageResult><MessageId>5fea7756-0ea4-451a-a703-a558b933e274</MessageId></SendMessageResult></SendMessageResponse>")); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer ts.Close()
+
+	sr := tracetest.NewSpanRecorder()
+	tr := NewTransport(http.DefaultTransport)
+	tr.provider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	c := http.Client{Transport: tr}
+
+	r, err := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader("Action=SendMessage&QueueUrl=https%3A%2F%2Fsqs.us-east-1.amazonaws.com%2F123456789012%2Forders&MessageBody=hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKID/20220101/us-east-1/sqs/aws4_request, SignedHeaders=host, Signature=abc")
+	res, err := c.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(res.Body); err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, res.Body.Close())
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 1)
+	attrs := testutil.AttributesMap(spans[0].Attributes())
+	assert.Equal(t, "SendMessage", attrs["rpc.method"].AsString())
+	assert.Equal(t, []string{"5fea7756-0ea4-451a-a703-a558b933e274"}, attrs["aws.message_ids"].AsStringSlice())
+}
+
+func TestTransportAWSMessageIDsLargeBatch(t *testing.T) {
+	var ids []string
+	var entries []string
+	for i := 0; i < 100; i++ {
+		id := fmt.Sprintf("5fea7756-0ea4-451a-a703-%012d", i)
+		ids = append(ids, id)
+		entries = append(entries, fmt.Sprintf(`{"Id":"%d","MessageId":"%s","MD5OfMessageBody":"5d41402abc4b2a76b9719d911017c592"}`, i, id))
+	}
+	response := `{"Successful":[` + strings.Join(entries, ",") + `]}`
+	assert.Greater(t, len(response), defaultMaxBodySize)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		if _, err := w.Write([]byte(response)); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer ts.Close()
+
+	for _, opts := range [][]TransportOption{nil, {WithoutBodies()}} {
+		sr := tracetest.NewSpanRecorder()
+		tr := NewTransport(http.DefaultTransport, opts...)
+		tr.provider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+		c := http.Client{Transport: tr}
+
+		r, err := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(`{"QueueUrl":"https://sqs.us-east-1.amazonaws.com/123456789012/orders","Entries":[]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("X-Amz-Target", "AmazonSQS.SendMessageBatch")
+		r.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKID/20220101/us-east-1/sqs/aws4_request, SignedHeaders=host, Signature=abc")
+		res, err := c.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ioutil.ReadAll(res.Body); err != nil {
+			t.Fatal(err)
+		}
+		assert.NoError(t, res.Body.Close())
+
+		spans := sr.Ended()
+		assert.Len(t, spans, 1)
+		attrs := testutil.AttributesMap(spans[0].Attributes())
+		assert.Equal(t, ids, attrs["aws.message_ids"].AsStringSlice())
+	}
+}
+
+func TestTransportDynamoDBLargeItem(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := w.Write([]byte("{}")); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer ts.Close()
+
+	os.Setenv("LUMIGO_TRACER_TOKEN", "token")
+	defer os.Unsetenv("LUMIGO_TRACER_TOKEN")
+	assert.NoError(t, loadConfig(Config{DynamoDBKeys: map[string][]string{"orders": {"id"}}}))
+	defer func() { assert.NoError(t, loadConfig(Config{})) }()
+
+	testcases := []struct {
+		testname string
+		size     int
+		hashed   bool
+	}{
+		{testname: "larger than the body capture", size: 2 * defaultMaxBodySize, hashed: true},
+		// only the start and the end of the body are kept, a partial item is not hashed
+		{testname: "larger than the AWS capture", size: 4 * awsCaptureSize, hashed: false},
+	}
+	for _, tc := range testcases {
+		// the SDKs marshal the table name after the item
+		body := fmt.Sprintf(`{"Item":{"id":{"S":"1"},"note":{"S":"%s"}},"TableName":"orders"}`, strings.Repeat("a", tc.size))
+		for _, opts := range [][]TransportOption{nil, {WithoutBodies()}} {
+			sr := tracetest.NewSpanRecorder()
+			tr := NewTransport(http.DefaultTransport, opts...)
+			tr.provider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+			c := http.Client{Transport: tr}
+
+			r, err := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.Header.Set("X-Amz-Target", "DynamoDB_20120810.PutItem")
+			r.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKID/20220101/us-east-1/dynamodb/aws4_request, SignedHeaders=host, Signature=abc")
+			res, err := c.Do(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ioutil.ReadAll(res.Body); err != nil {
+				t.Fatal(err)
+			}
+			assert.NoError(t, res.Body.Close())
+
+			spans := sr.Ended()
+			assert.Len(t, spans, 1, tc.testname)
+			attrs := testutil.AttributesMap(spans[0].Attributes())
+			assert.Equal(t, "orders", attrs["aws.resource_name"].AsString(), tc.testname)
+			if tc.hashed {
+				assert.Equal(t, []string{awsparser.ItemHash("orders", json.RawMessage(`{"id":{"S":"1"}}`))}, attrs["aws.message_ids"].AsStringSlice(), tc.testname)
+			} else {
+				assert.NotContains(t, attrs, attribute.Key("aws.message_ids"), tc.testname)
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package lumigotracer
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/lumigo-io/go-tracer-beta/internal/awsoperation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// awsOperationKey the context key of the operation of an aws-sdk-go
+// request, so that its span is not mistaken for a parent span
+type awsOperationKey struct{}
+
+// awsOperation tracks an aws-sdk-go request across its attempts
+type awsOperation struct {
+	span     trace.Span
+	attempts int
+}
+
+// InstrumentSession adds a span per operation to the clients of an
+// aws-sdk-go session, the HttpSpans of the operation attempts are
+// nested under it if the session HTTP client uses the lumigo Transport:
+//
+//	sess := session.Must(session.NewSession())
+//	lumigotracer.InstrumentSession(sess)
+func InstrumentSession(sess *session.Session) {
+	// validate is the first step, a request which fails it is still traced
+	sess.Handlers.Validate.PushFrontNamed(request.NamedHandler{
+		Name: "lumigo.StartOperationSpan",
+		Fn:   startOperationSpan,
+	})
+	// the params are serialized by the build handlers
+	sess.Handlers.Build.PushFrontNamed(request.NamedHandler{
+		Name: "lumigo.InjectMessagingContext",
+		Fn:   injectRequestMessagingContext,
+	})
+	sess.Handlers.CompleteAttempt.PushBackNamed(request.NamedHandler{
+		Name: "lumigo.RecordOperationAttempt",
+		Fn:   recordOperationAttempt,
+	})
+	sess.Handlers.Complete.PushBackNamed(request.NamedHandler{
+		Name: "lumigo.EndOperationSpan",
+		Fn:   endOperationSpan,
+	})
+}
+
+func startOperationSpan(r *request.Request) {
+	if _, ok := r.Context().Value(awsOperationKey{}).(*awsOperation); ok {
+		return
+	}
+	service := awsoperation.ServiceName(r.ClientInfo.ServiceID)
+	ctx, span := awsoperation.StartSpan(r.Context(), service, r.Operation.Name, aws.StringValue(r.Config.Region), r.Params)
+	r.SetContext(context.WithValue(ctx, awsOperationKey{}, &awsOperation{span: span}))
+}
+
+func injectRequestMessagingContext(r *request.Request) {
+	r.Params = awsoperation.InjectMessagingContext(r.Context(), awsoperation.ServiceName(r.ClientInfo.ServiceID), r.Operation.Name, r.Params)
+}
+
+func recordOperationAttempt(r *request.Request) {
+	op, ok := r.Context().Value(awsOperationKey{}).(*awsOperation)
+	if !ok {
+		return
+	}
+	op.attempts++
+	if r.Error != nil {
+		awsoperation.AddAttemptFailedEvent(op.span, op.attempts, r.Error)
+	}
+}
+
+func endOperationSpan(r *request.Request) {
+	op, ok := r.Context().Value(awsOperationKey{}).(*awsOperation)
+	if !ok {
+		return
+	}
+	var statusCode int
+	// Send sets an empty response if no response was received
+	if r.HTTPResponse != nil {
+		statusCode = r.HTTPResponse.StatusCode
+	}
+	awsoperation.EndSpan(op.span, r.RequestID, statusCode, op.attempts, r.Error)
+}
@@ -0,0 +1,128 @@
+package lumigotracer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/lumigo-io/go-tracer-beta/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newTestSession(t *testing.T, endpoint string) *session.Session {
+	// a custom CA bundle needs an *http.Transport
+	t.Setenv("AWS_CA_BUNDLE", "")
+	sess, err := session.NewSession(&aws.Config{
+		Endpoint:    aws.String(endpoint),
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("AKID", "SECRET", ""),
+		HTTPClient:  &http.Client{Transport: NewTransport(http.DefaultTransport)},
+		MaxRetries:  aws.Int(2),
+		SleepDelay:  func(time.Duration) {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	InstrumentSession(sess)
+	return sess
+}
+
+func TestInstrumentSession(t *testing.T) {
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("X-Amzn-Requestid", "req-1")
+		if attempts == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if _, err := w.Write([]byte("{}")); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer ts.Close()
+
+	sr := tracetest.NewSpanRecorder()
+	previousProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	defer otel.SetTracerProvider(previousProvider)
+
+	svc := dynamodb.New(newTestSession(t, ts.URL))
+	_, err := svc.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String("orders"),
+		Item: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String("1")},
+		},
+	})
+	assert.NoError(t, err)
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 3)
+	operation := spans[2]
+	assert.Equal(t, "dynamodb.PutItem", operation.Name())
+	for _, httpSpan := range spans[:2] {
+		assert.Equal(t, "HttpSpan", httpSpan.Name())
+		assert.Equal(t, operation.SpanContext().SpanID(), httpSpan.Parent().SpanID())
+	}
+
+	attrs := testutil.AttributesMap(operation.Attributes())
+	assert.Equal(t, "aws-api", attrs["rpc.system"].AsString())
+	assert.Equal(t, "dynamodb", attrs["rpc.service"].AsString())
+	assert.Equal(t, "PutItem", attrs["rpc.method"].AsString())
+	assert.Equal(t, "us-east-1", attrs["aws.region"].AsString())
+	assert.Equal(t, "orders", attrs["aws.resource_name"].AsString())
+	assert.Equal(t, `{"Item":"<1 items>","TableName":"orders"}`, attrs["aws.params"].AsString())
+	assert.Equal(t, "req-1", attrs["aws.request_id"].AsString())
+	assert.Equal(t, int64(2), attrs["aws.attempts"].AsInt64())
+	assert.Equal(t, int64(http.StatusOK), attrs["http.status_code"].AsInt64())
+
+	events := operation.Events()
+	assert.Len(t, events, 1)
+	assert.Equal(t, "aws.attempt_failed", events[0].Name)
+	assert.Contains(t, events[0].Attributes, attribute.Int("aws.attempt", 1))
+}
+
+func TestInstrumentSessionError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	sr := tracetest.NewSpanRecorder()
+	previousProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	defer otel.SetTracerProvider(previousProvider)
+
+	svc := dynamodb.New(newTestSession(t, ts.URL))
+	_, err := svc.GetItem(&dynamodb.GetItemInput{TableName: aws.String("orders")})
+	assert.Error(t, err)
+
+	// the missing Key fails the validation before any attempt
+	spans := sr.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.NotContains(t, testutil.AttributesMap(spans[0].Attributes()), attribute.Key("aws.attempts"))
+
+	_, err = svc.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String("orders"),
+		Key:       map[string]*dynamodb.AttributeValue{"id": {S: aws.String("1")}},
+	})
+	assert.Error(t, err)
+
+	spans = sr.Ended()
+	assert.Len(t, spans, 3)
+	attrs := testutil.AttributesMap(spans[2].Attributes())
+	assert.Equal(t, codes.Error, spans[2].Status().Code)
+	assert.Equal(t, int64(1), attrs["aws.attempts"].AsInt64())
+	assert.Equal(t, int64(http.StatusBadRequest), attrs["http.status_code"].AsInt64())
+}
@@ -0,0 +1,53 @@
+package lumigotracer
+
+import (
+	"context"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/lumigo-io/go-tracer-beta/internal/awsoperation"
+)
+
+// AppendMiddlewares adds a span per operation to the clients of an
+// aws-sdk-go-v2 config, the HttpSpans of the operation attempts are
+// nested under it:
+//
+//	cfg, _ := config.LoadDefaultConfig(ctx)
+//	lumigotracer.AppendMiddlewares(&cfg.APIOptions)
+func AppendMiddlewares(apiOptions *[]func(*middleware.Stack) error) {
+	*apiOptions = append(*apiOptions, addOperationMiddleware)
+}
+
+func addOperationMiddleware(stack *middleware.Stack) error {
+	// the service metadata is registered by the first initialize middlewares
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("LumigoOperationSpan", handleOperation), middleware.After)
+}
+
+func handleOperation(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (
+	out middleware.InitializeOutput, metadata middleware.Metadata, err error,
+) {
+	service := awsoperation.ServiceName(awsmiddleware.GetServiceID(ctx))
+	operation := awsmiddleware.GetOperationName(ctx)
+	ctx, span := awsoperation.StartSpan(ctx, service, operation, awsmiddleware.GetRegion(ctx), in.Parameters)
+	in.Parameters = awsoperation.InjectMessagingContext(ctx, service, operation, in.Parameters)
+
+	out, metadata, err = next.HandleInitialize(ctx, in)
+
+	var statusCode, attempts int
+	if resp, ok := awsmiddleware.GetRawResponse(metadata).(*smithyhttp.Response); ok {
+		statusCode = resp.StatusCode
+	}
+	if results, ok := retry.GetAttemptResults(metadata); ok {
+		attempts = len(results.Results)
+		for i, result := range results.Results {
+			if result.Err != nil {
+				awsoperation.AddAttemptFailedEvent(span, i+1, result.Err)
+			}
+		}
+	}
+	requestID, _ := awsmiddleware.GetRequestIDMetadata(metadata)
+	awsoperation.EndSpan(span, requestID, statusCode, attempts, err)
+	return out, metadata, err
+}
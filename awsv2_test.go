@@ -0,0 +1,190 @@
+package lumigotracer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/lumigo-io/go-tracer-beta/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type putItemInput struct {
+	TableName *string
+	Item      map[string]string
+}
+
+// newTestStack builds the middleware stack of an aws-sdk-go-v2
+// operation calling the given endpoint
+func newTestStack(t *testing.T, endpoint string) *middleware.Stack {
+	stack := middleware.NewStack("PutItem", smithyhttp.NewStackRequest)
+	assert.NoError(t, stack.Initialize.Add(&awsmiddleware.RegisterServiceMetadata{
+		ServiceID:     "DynamoDB",
+		Region:        "us-east-1",
+		OperationName: "PutItem",
+	}, middleware.Before))
+	assert.NoError(t, stack.Serialize.Add(middleware.SerializeMiddlewareFunc("OperationSerializer",
+		func(ctx context.Context, in middleware.SerializeInput, next middleware.SerializeHandler) (middleware.SerializeOutput, middleware.Metadata, error) {
+			req := in.Request.(*smithyhttp.Request)
+			req.Method = http.MethodPost
+			req.URL, _ = url.Parse(endpoint)
+			return next.HandleSerialize(ctx, in)
+		}), middleware.After))
+	assert.NoError(t, stack.Deserialize.Add(middleware.DeserializeMiddlewareFunc("OperationDeserializer",
+		func(ctx context.Context, in middleware.DeserializeInput, next middleware.DeserializeHandler) (middleware.DeserializeOutput, middleware.Metadata, error) {
+			out, metadata, err := next.HandleDeserialize(ctx, in)
+			if err != nil {
+				return out, metadata, err
+			}
+			resp := out.RawResponse.(*smithyhttp.Response)
+			if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+				return out, metadata, err
+			}
+			if err := resp.Body.Close(); err != nil {
+				return out, metadata, err
+			}
+			if resp.StatusCode >= 300 {
+				return out, metadata, &smithyhttp.ResponseError{Response: resp, Err: errors.New("internal error")}
+			}
+			return out, metadata, nil
+		}), middleware.After))
+	assert.NoError(t, awsmiddleware.AddRequestIDRetrieverMiddleware(stack))
+	assert.NoError(t, awsmiddleware.AddRawResponseToMetadata(stack))
+	assert.NoError(t, retry.AddRetryMiddlewares(stack, retry.AddRetryMiddlewaresOptions{
+		Retryer: retry.NewStandard(func(o *retry.StandardOptions) {
+			o.Backoff = retry.BackoffDelayerFunc(func(int, error) (time.Duration, error) { return 0, nil })
+		}),
+	}))
+
+	var apiOptions []func(*middleware.Stack) error
+	AppendMiddlewares(&apiOptions)
+	for _, fn := range apiOptions {
+		assert.NoError(t, fn(stack))
+	}
+	return stack
+}
+
+func TestAppendMiddlewares(t *testing.T) {
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("X-Amzn-Requestid", "req-1")
+		if attempts == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	sr := tracetest.NewSpanRecorder()
+	previousProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	defer otel.SetTracerProvider(previousProvider)
+
+	client := &http.Client{Transport: NewTransport(http.DefaultTransport)}
+	handler := middleware.DecorateHandler(smithyhttp.NewClientHandler(client), newTestStack(t, ts.URL))
+	_, _, err := handler.Handle(context.Background(), &putItemInput{TableName: aws.String("orders")})
+	assert.NoError(t, err)
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 3)
+	operation := spans[2]
+	assert.Equal(t, "dynamodb.PutItem", operation.Name())
+	for _, httpSpan := range spans[:2] {
+		assert.Equal(t, "HttpSpan", httpSpan.Name())
+		assert.Equal(t, operation.SpanContext().SpanID(), httpSpan.Parent().SpanID())
+	}
+
+	attrs := testutil.AttributesMap(operation.Attributes())
+	assert.Equal(t, "aws-api", attrs["rpc.system"].AsString())
+	assert.Equal(t, "dynamodb", attrs["rpc.service"].AsString())
+	assert.Equal(t, "PutItem", attrs["rpc.method"].AsString())
+	assert.Equal(t, "us-east-1", attrs["aws.region"].AsString())
+	assert.Equal(t, "orders", attrs["aws.resource_name"].AsString())
+	assert.Equal(t, "req-1", attrs["aws.request_id"].AsString())
+	assert.Equal(t, int64(2), attrs["aws.attempts"].AsInt64())
+	assert.Equal(t, int64(http.StatusOK), attrs["http.status_code"].AsInt64())
+
+	events := operation.Events()
+	assert.Len(t, events, 1)
+	assert.Equal(t, "aws.attempt_failed", events[0].Name)
+	assert.Contains(t, events[0].Attributes, attribute.Int("aws.attempt", 1))
+}
+
+func TestAppendMiddlewaresError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	sr := tracetest.NewSpanRecorder()
+	previousProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	defer otel.SetTracerProvider(previousProvider)
+
+	handler := middleware.DecorateHandler(smithyhttp.NewClientHandler(http.DefaultClient), newTestStack(t, ts.URL))
+	_, _, err := handler.Handle(context.Background(), &putItemInput{})
+	assert.Error(t, err)
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	attrs := testutil.AttributesMap(spans[0].Attributes())
+	assert.Equal(t, int64(1), attrs["aws.attempts"].AsInt64())
+	assert.NotContains(t, attrs, attribute.Key("aws.resource_name"))
+}
+
+type sendMessageInput struct {
+	QueueUrl          *string
+	MessageAttributes map[string]struct {
+		DataType    *string
+		StringValue *string
+	}
+}
+
+func TestAppendMiddlewaresInjectsACopy(t *testing.T) {
+	ctx := testutil.WithTraceContextPropagator(t)
+
+	stack := middleware.NewStack("SendMessage", smithyhttp.NewStackRequest)
+	assert.NoError(t, stack.Initialize.Add(&awsmiddleware.RegisterServiceMetadata{
+		ServiceID:     "SQS",
+		Region:        "us-east-1",
+		OperationName: "SendMessage",
+	}, middleware.Before))
+	var apiOptions []func(*middleware.Stack) error
+	AppendMiddlewares(&apiOptions)
+	for _, fn := range apiOptions {
+		assert.NoError(t, fn(stack))
+	}
+	// the params the serializers receive
+	var sent *sendMessageInput
+	assert.NoError(t, stack.Initialize.Add(middleware.InitializeMiddlewareFunc("CaptureParams",
+		func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			sent = in.Parameters.(*sendMessageInput)
+			return middleware.InitializeOutput{}, middleware.Metadata{}, nil
+		}), middleware.After))
+
+	input := &sendMessageInput{QueueUrl: aws.String("queue")}
+	handler := middleware.DecorateHandler(smithyhttp.NewClientHandler(http.DefaultClient), stack)
+	_, _, err := handler.Handle(ctx, input)
+	assert.NoError(t, err)
+
+	assert.NotNil(t, sent)
+	assert.Equal(t, "queue", aws.ToString(sent.QueueUrl))
+	assert.Contains(t, sent.MessageAttributes, "traceparent")
+	assert.Nil(t, input.MessageAttributes)
+}
@@ -0,0 +1,204 @@
+package lumigotracer
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// binaryContentTypes content types which are never captured as text
+var binaryContentTypes = []string{
+	"application/octet-stream",
+	"application/protobuf",
+	"application/x-protobuf",
+	"application/vnd.google.protobuf",
+	"application/grpc",
+	"application/zip",
+	"application/gzip",
+	"application/pdf",
+}
+
+// binaryContentTypePrefixes content type families which are never
+// captured as text
+var binaryContentTypePrefixes = []string{
+	"image/",
+	"audio/",
+	"video/",
+	"font/",
+}
+
+// formatBody renders a captured body prefix as a span attribute,
+// decoding it according to the headers that came along with it
+func formatBody(header http.Header, body []byte, size int64, limit int) string {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = ""
+	}
+
+	if encoding := header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		decoded, decodeErr := decompressBody(encoding, body, limit)
+		if decodeErr != nil {
+			logger.WithError(decodeErr).Warn("failed to decompress body")
+			return summarizeBody(fmt.Sprintf("%s %s", encoding, mediaType), size)
+		}
+		body = decoded
+	}
+	if strings.EqualFold(header.Get("Content-Transfer-Encoding"), "base64") {
+		body = decodeBase64Body(body, limit)
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" {
+		return truncateBody(formatMultipartBody(body, params["boundary"], limit), limit)
+	}
+	if isBinaryBody(mediaType, body) {
+		return summarizeBody(mediaType, size)
+	}
+	return truncateBody(string(trimIncompleteRune(body)), limit)
+}
+
+// decompressBody decompresses up to limit bytes of a possibly
+// truncated compressed body
+func decompressBody(encoding string, body []byte, limit int) ([]byte, error) {
+	var reader io.Reader
+	switch strings.ToLower(encoding) {
+	case "gzip", "x-gzip":
+		gzipReader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		reader = gzipReader
+	case "deflate":
+		// deflate in HTTP is zlib wrapped, though some servers send raw deflate
+		zlibReader, err := zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			reader = flate.NewReader(bytes.NewReader(body))
+		} else {
+			reader = zlibReader
+		}
+	default:
+		return nil, errors.Errorf("unsupported content encoding: %s", encoding)
+	}
+	decoded, err := io.ReadAll(io.LimitReader(reader, int64(limit)))
+	// the captured prefix may end in the middle of the stream
+	if err != nil && err != io.ErrUnexpectedEOF && len(decoded) == 0 {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// decodeBase64Body decodes as much as possible of a base64 body,
+// falling back to the raw body if nothing can be decoded
+func decodeBase64Body(body []byte, limit int) []byte {
+	decoder := base64.NewDecoder(base64.StdEncoding, bytes.NewReader(body))
+	decoded, err := io.ReadAll(io.LimitReader(decoder, int64(limit)))
+	if err != nil && len(decoded) == 0 {
+		return body
+	}
+	return decoded
+}
+
+// formatMultipartBody renders the parts of a multipart body as
+// a json object of field names to values, summarizing files and
+// binary parts
+func formatMultipartBody(body []byte, boundary string, limit int) string {
+	fields := url.Values{}
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			// io.EOF or a part cut by the capture limit
+			break
+		}
+		content, readErr := io.ReadAll(io.LimitReader(part, int64(limit)))
+		if readErr != nil && len(content) == 0 {
+			break
+		}
+		if strings.EqualFold(part.Header.Get("Content-Transfer-Encoding"), "base64") {
+			content = decodeBase64Body(content, limit)
+		}
+		name := part.FormName()
+		mediaType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		switch {
+		case part.FileName() != "" && isBinaryBody(mediaType, content):
+			fields.Add(name, summarizeBody(fmt.Sprintf("%s file %s", mediaType, part.FileName()), int64(len(content))))
+		case isBinaryBody(mediaType, content):
+			fields.Add(name, summarizeBody(mediaType, int64(len(content))))
+		default:
+			fields.Add(name, string(content))
+		}
+	}
+	if len(fields) == 0 {
+		return ""
+	}
+	var fieldsJson bytes.Buffer
+	enc := json.NewEncoder(&fieldsJson)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(fields); err != nil {
+		logger.WithError(err).Error("failed to format multipart body")
+		return ""
+	}
+	return strings.TrimSuffix(fieldsJson.String(), "\n")
+}
+
+// isBinaryBody checks by media type, and by content when the
+// media type is unknown, whether a body should be summarized
+func isBinaryBody(mediaType string, body []byte) bool {
+	for _, binaryType := range binaryContentTypes {
+		if mediaType == binaryType {
+			return true
+		}
+	}
+	for _, prefix := range binaryContentTypePrefixes {
+		if strings.HasPrefix(mediaType, prefix) {
+			return true
+		}
+	}
+	return !utf8.Valid(trimIncompleteRune(body))
+}
+
+// trimIncompleteRune drops a trailing rune cut by the capture limit
+// so that truncated text is not considered binary nor split
+func trimIncompleteRune(body []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(body); i++ {
+		if utf8.RuneStart(body[len(body)-i]) {
+			if !utf8.FullRune(body[len(body)-i:]) {
+				return body[:len(body)-i]
+			}
+			break
+		}
+	}
+	return body
+}
+
+func summarizeBody(mediaType string, size int64) string {
+	mediaType = strings.TrimSpace(mediaType)
+	if mediaType == "" {
+		mediaType = "binary"
+	}
+	return fmt.Sprintf("<%s body, %d bytes>", mediaType, size)
+}
+
+// truncateBody cuts body to at most limit bytes, backing off to the
+// start of a rune so that a multibyte rune is not split
+func truncateBody(body string, limit int) string {
+	if len(body) <= limit {
+		return body
+	}
+	for i := 0; i < utf8.UTFMax && limit > 0 && !utf8.RuneStart(body[limit]); i++ {
+		limit--
+	}
+	return body[:limit]
+}
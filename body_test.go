@@ -0,0 +1,224 @@
+package lumigotracer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func gzipBytes(t *testing.T, content string) []byte {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zlibBytes(t *testing.T, content string) []byte {
+	var buf bytes.Buffer
+	writer := zlib.NewWriter(&buf)
+	if _, err := writer.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFormatBody(t *testing.T) {
+	longText := strings.Repeat("a", 100)
+	gzipped := gzipBytes(t, longText)
+	pngHeader := []byte{0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a}
+
+	testcases := []struct {
+		testname string
+		header   http.Header
+		body     []byte
+		size     int64
+		limit    int
+		expected string
+	}{
+		{
+			testname: "plain json",
+			header:   http.Header{"Content-Type": []string{"application/json"}},
+			body:     []byte(`{"name":"test"}`),
+			size:     15,
+			limit:    defaultMaxBodySize,
+			expected: `{"name":"test"}`,
+		},
+		{
+			testname: "no content type",
+			header:   http.Header{},
+			body:     []byte("Hello, world!"),
+			size:     13,
+			limit:    defaultMaxBodySize,
+			expected: "Hello, world!",
+		},
+		{
+			testname: "text truncated to limit",
+			header:   http.Header{"Content-Type": []string{"text/plain; charset=utf-8"}},
+			body:     []byte(longText),
+			size:     100,
+			limit:    10,
+			expected: longText[:10],
+		},
+		{
+			testname: "truncated multibyte text",
+			header:   http.Header{"Content-Type": []string{"text/plain"}},
+			body:     []byte("héllo wörld")[:9],
+			size:     13,
+			limit:    defaultMaxBodySize,
+			expected: "héllo w",
+		},
+		{
+			testname: "multibyte text truncated to limit",
+			header:   http.Header{"Content-Type": []string{"text/plain"}},
+			body:     []byte("héllo wörld"),
+			size:     13,
+			limit:    9,
+			expected: "héllo w",
+		},
+		{
+			testname: "gzip",
+			header: http.Header{
+				"Content-Type":     []string{"text/plain"},
+				"Content-Encoding": []string{"gzip"},
+			},
+			body:     gzipped,
+			size:     int64(len(gzipped)),
+			limit:    defaultMaxBodySize,
+			expected: longText,
+		},
+		{
+			testname: "gzip decompressed up to limit",
+			header: http.Header{
+				"Content-Type":     []string{"text/plain"},
+				"Content-Encoding": []string{"gzip"},
+			},
+			body:     gzipped,
+			size:     int64(len(gzipped)),
+			limit:    20,
+			expected: longText[:20],
+		},
+		{
+			testname: "deflate",
+			header: http.Header{
+				"Content-Type":     []string{"application/json"},
+				"Content-Encoding": []string{"deflate"},
+			},
+			body:     zlibBytes(t, `{"name":"test"}`),
+			size:     23,
+			limit:    defaultMaxBodySize,
+			expected: `{"name":"test"}`,
+		},
+		{
+			testname: "unsupported encoding",
+			header: http.Header{
+				"Content-Type":     []string{"application/json"},
+				"Content-Encoding": []string{"br"},
+			},
+			body:     []byte{0x1b, 0x0e},
+			size:     120,
+			limit:    defaultMaxBodySize,
+			expected: "<br application/json body, 120 bytes>",
+		},
+		{
+			testname: "image",
+			header:   http.Header{"Content-Type": []string{"image/png"}},
+			body:     pngHeader,
+			size:     4096,
+			limit:    defaultMaxBodySize,
+			expected: "<image/png body, 4096 bytes>",
+		},
+		{
+			testname: "protobuf",
+			header:   http.Header{"Content-Type": []string{"application/x-protobuf"}},
+			body:     []byte{0x0a, 0x04, 't', 'e', 's', 't'},
+			size:     6,
+			limit:    defaultMaxBodySize,
+			expected: "<application/x-protobuf body, 6 bytes>",
+		},
+		{
+			testname: "unknown binary",
+			header:   http.Header{},
+			body:     pngHeader,
+			size:     8,
+			limit:    defaultMaxBodySize,
+			expected: "<binary body, 8 bytes>",
+		},
+		{
+			testname: "base64",
+			header: http.Header{
+				"Content-Type":              []string{"text/plain"},
+				"Content-Transfer-Encoding": []string{"base64"},
+			},
+			body:     []byte(base64.StdEncoding.EncodeToString([]byte("Hello, world!"))),
+			size:     20,
+			limit:    defaultMaxBodySize,
+			expected: "Hello, world!",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testname, func(t *testing.T) {
+			assert.Equal(t, tc.expected, formatBody(tc.header, tc.body, tc.size, tc.limit))
+		})
+	}
+}
+
+func TestFormatMultipartBody(t *testing.T) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	assert.NoError(t, writer.WriteField("name", "test"))
+	assert.NoError(t, writer.WriteField("tag", "a"))
+	assert.NoError(t, writer.WriteField("tag", "b"))
+
+	fileHeader := make(textproto.MIMEHeader)
+	fileHeader.Set("Content-Disposition", `form-data; name="photo"; filename="photo.png"`)
+	fileHeader.Set("Content-Type", "image/png")
+	part, err := writer.CreatePart(fileHeader)
+	assert.NoError(t, err)
+	_, err = part.Write([]byte{0x89, 0x50, 0x4e, 0x47})
+	assert.NoError(t, err)
+
+	encodedHeader := make(textproto.MIMEHeader)
+	encodedHeader.Set("Content-Disposition", `form-data; name="note"`)
+	encodedHeader.Set("Content-Transfer-Encoding", "base64")
+	part, err = writer.CreatePart(encodedHeader)
+	assert.NoError(t, err)
+	_, err = part.Write([]byte(base64.StdEncoding.EncodeToString([]byte("hello"))))
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+
+	header := http.Header{"Content-Type": []string{writer.FormDataContentType()}}
+	body := formatBody(header, buf.Bytes(), int64(buf.Len()), defaultMaxBodySize)
+	assert.Equal(t, `{"name":["test"],"note":["hello"],"photo":["<image/png file photo.png body, 4 bytes>"],"tag":["a","b"]}`, body)
+}
+
+func TestFormatMultipartBodyTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	assert.NoError(t, writer.WriteField("name", "test"))
+	assert.NoError(t, writer.WriteField("description", strings.Repeat("a", 100)))
+	assert.NoError(t, writer.Close())
+
+	header := http.Header{"Content-Type": []string{writer.FormDataContentType()}}
+	// cut inside the description value, before the closing boundary
+	truncated := buf.Bytes()[:buf.Len()-len(writer.Boundary())-20]
+	body := formatBody(header, truncated, int64(buf.Len()), defaultMaxBodySize)
+	assert.True(t, strings.HasPrefix(body, `{"description":["aaa`), body)
+	assert.True(t, strings.HasSuffix(body, `"name":["test"]}`), body)
+}
@@ -0,0 +1,123 @@
+package lumigotracer
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/lumigo-io/go-tracer-beta/internal/telemetry"
+	"github.com/pkg/errors"
+)
+
+const (
+	// GzipSpans compresses the span files with gzip
+	GzipSpans = "gzip"
+	// ZstdSpans compresses the span files with zstd
+	ZstdSpans = "zstd"
+)
+
+// spansFormatVersion the version of the compressed span files format
+const spansFormatVersion = 1
+
+// spansSuffixes the file name suffixes of the compressed span files
+var spansSuffixes = map[string]string{
+	GzipSpans: ".gz",
+	ZstdSpans: ".zst",
+}
+
+// zstdSkippableFrameMagic the magic number of the zstd skippable frames,
+// which decoders ignore
+const zstdSkippableFrameMagic = 0x184D2A50
+
+// spansFormatMarker marks the compressed span files inside their format,
+// the comment of the gzip header or a zstd skippable frame before the
+// compressed json spans, so that the files stay valid gzip or zstd
+func spansFormatMarker() string {
+	return fmt.Sprintf("lumigo-spans/%d", spansFormatVersion)
+}
+
+// writeZstdSkippableFrame writes a zstd skippable frame holding payload
+func writeZstdSkippableFrame(w io.Writer, payload string) error {
+	frame := make([]byte, 8, 8+len(payload))
+	binary.LittleEndian.PutUint32(frame, zstdSkippableFrameMagic)
+	binary.LittleEndian.PutUint32(frame[4:], uint32(len(payload)))
+	_, err := w.Write(append(frame, payload...))
+	return err
+}
+
+// spansEncoder writes the json spans of a span file, reusing its
+// compressors across files
+type spansEncoder struct {
+	compression string
+	gzipWriter  *gzip.Writer
+	zstdWriter  *zstd.Encoder
+}
+
+// newSpansEncoder returns an encoder of uncompressed span files if
+// compression is empty
+func newSpansEncoder(compression string) *spansEncoder {
+	return &spansEncoder{compression: compression}
+}
+
+// suffix returns the file name suffix of the span files
+func (enc *spansEncoder) suffix() string {
+	return spansSuffixes[enc.compression]
+}
+
+// encode writes the spans to w
+func (enc *spansEncoder) encode(w io.Writer, spans []telemetry.Span) error {
+	compressor, err := enc.compressor(w)
+	if err != nil {
+		return err
+	}
+	if compressor == nil {
+		return json.NewEncoder(w).Encode(spans)
+	}
+	if enc.compression == ZstdSpans {
+		if err := writeZstdSkippableFrame(w, spansFormatMarker()); err != nil {
+			return errors.Wrap(err, "failed to write spans format marker")
+		}
+	}
+	if err := json.NewEncoder(compressor).Encode(spans); err != nil {
+		compressor.Close() // nolint
+		return err
+	}
+	return errors.Wrapf(compressor.Close(), "failed to compress spans with %s", enc.compression)
+}
+
+// compressor returns the compressor writing to w, nil if the span
+// files are not compressed
+func (enc *spansEncoder) compressor(w io.Writer) (io.WriteCloser, error) {
+	switch enc.compression {
+	case GzipSpans:
+		if enc.gzipWriter == nil {
+			gzipWriter, err := gzip.NewWriterLevel(w, gzip.BestSpeed)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to create gzip writer")
+			}
+			enc.gzipWriter = gzipWriter
+		} else {
+			enc.gzipWriter.Reset(w)
+		}
+		// reset clears the header
+		enc.gzipWriter.Header.Comment = spansFormatMarker()
+		return enc.gzipWriter, nil
+	case ZstdSpans:
+		if enc.zstdWriter == nil {
+			zstdWriter, err := zstd.NewWriter(w,
+				zstd.WithEncoderLevel(zstd.SpeedFastest),
+				zstd.WithEncoderConcurrency(1))
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to create zstd writer")
+			}
+			enc.zstdWriter = zstdWriter
+		} else {
+			enc.zstdWriter.Reset(w)
+		}
+		return enc.zstdWriter, nil
+	}
+	return nil, nil
+}
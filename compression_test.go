@@ -0,0 +1,94 @@
+package lumigotracer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/lumigo-io/go-tracer-beta/internal/telemetry"
+	"github.com/stretchr/testify/assert"
+)
+
+// readCompressedSpans reads a span file with the standard decoder of
+// its suffix and checks the format marker
+func readCompressedSpans(t *testing.T, file string) []telemetry.Span {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decompressed io.Reader
+	switch filepath.Ext(file) {
+	case ".gz":
+		gzipReader, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "lumigo-spans/1", gzipReader.Header.Comment)
+		decompressed = gzipReader
+	case ".zst":
+		assert.Equal(t, uint32(zstdSkippableFrameMagic), binary.LittleEndian.Uint32(content))
+		size := binary.LittleEndian.Uint32(content[4:])
+		assert.Equal(t, "lumigo-spans/1", string(content[8:8+size]))
+		zstdReader, err := zstd.NewReader(bytes.NewReader(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer zstdReader.Close()
+		decompressed = zstdReader
+	default:
+		t.Fatalf("unexpected span file: %s", file)
+	}
+
+	var spans []telemetry.Span
+	if err := json.NewDecoder(decompressed).Decode(&spans); err != nil {
+		t.Fatal(err)
+	}
+	return spans
+}
+
+func TestExporterCompressedSpans(t *testing.T) {
+	event := strings.Repeat(`{"key":"value"}`, 1000)
+
+	testcases := []struct {
+		compression string
+		suffix      string
+	}{
+		{compression: GzipSpans, suffix: "_end.gz"},
+		{compression: ZstdSpans, suffix: "_end.zst"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.compression, func(t *testing.T) {
+			dir := t.TempDir()
+			exp, err := newExporter(context.Background(), logger, dir, spansQuota{}, tc.compression)
+			assert.NoError(t, err)
+
+			// the compressors are reused across files
+			for _, id := range []string{"1", "2"} {
+				assert.NoError(t, exp.writeSpan([]telemetry.Span{{ID: id, Event: event}}, false))
+			}
+
+			files, err := spanFiles(dir)
+			assert.NoError(t, err)
+			assert.Len(t, files, 2)
+			for i, file := range files {
+				assert.True(t, strings.HasSuffix(file.Name(), tc.suffix), file.Name())
+				assert.Less(t, file.Size(), int64(len(event)))
+
+				spans := readCompressedSpans(t, filepath.Join(dir, file.Name()))
+				assert.Len(t, spans, 1)
+				assert.Equal(t, []string{"1", "2"}[i], spans[0].ID)
+				assert.Equal(t, event, spans[0].Event)
+			}
+		})
+	}
+}
@@ -1,7 +1,14 @@
 package lumigotracer
 
 import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lumigo-io/go-tracer-beta/internal/awsparser"
 	"github.com/spf13/viper"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 // Config describes the struct about the configuration
@@ -18,8 +25,124 @@ type Config struct {
 
 	// PrintStdout prints in stdout
 	PrintStdout bool
+
+	// HTTPHeadersAllowlist captures only these HTTP headers,
+	// all headers are captured if empty
+	HTTPHeadersAllowlist []string
+
+	// HTTPHeadersDenylist never captures these HTTP headers
+	HTTPHeadersDenylist []string
+
+	// SecretMaskingRegex masks the values of HTTP headers whose
+	// names match any of these patterns
+	SecretMaskingRegex []string
+
+	// secretMaskingRegexps the compiled SecretMaskingRegex
+	secretMaskingRegexps []*regexp.Regexp
+
+	// headers the HTTP headers filter built from the headers options
+	headers *headerFilter
+
+	// IgnoredDomains outgoing HTTP calls to hosts matching any of
+	// these patterns are not traced, on top of the built-in ones
+	IgnoredDomains []string
+
+	// IgnoredPaths outgoing HTTP calls to paths matching any of
+	// these patterns are not traced
+	IgnoredPaths []string
+
+	// ignoredDomainsRegexps the compiled IgnoredDomains with the built-in ones
+	ignoredDomainsRegexps []*regexp.Regexp
+
+	// ignoredPathsRegexps the compiled IgnoredPaths
+	ignoredPathsRegexps []*regexp.Regexp
+
+	// InstrumentDefaultTransport traces all the HTTP clients using
+	// http.DefaultTransport by replacing it, see InstrumentDefaultTransport
+	// for the clients it breaks. LUMIGO_INSTRUMENT_DEFAULT_TRANSPORT
+	// overrides it when set, false opting out
+	InstrumentDefaultTransport bool
+
+	// PropagateXRay injects the X-Amzn-Trace-Id header along with the W3C
+	// trace context and generates X-Ray compatible trace IDs
+	PropagateXRay bool
+
+	// DynamoDBKeys the key attribute names of DynamoDB tables, which the
+	// hashes of the items put to them are computed from. The whole items
+	// put to the other tables are hashed
+	DynamoDBKeys map[string][]string
+
+	// SpansDir the dir the span files are written to, SPANS_DIR if empty
+	SpansDir string
+
+	// SpansMaxBytes the most bytes of span files kept in SpansDir,
+	// 100MB if 0 and unlimited if negative
+	SpansMaxBytes int64
+
+	// SpansMaxFiles the most span files kept in SpansDir,
+	// unlimited if 0 or negative
+	SpansMaxFiles int
+
+	// SpansOverflowPolicy the spans dropped once SpansDir is full,
+	// either DropOldestSpans, the default, or DropNewestSpans
+	SpansOverflowPolicy string
+
+	// SpansMaxAge span files older than this are removed at container
+	// start, 1 hour if 0 and never if negative
+	SpansMaxAge time.Duration
+
+	// SpansCompression compresses the span files, either GzipSpans
+	// or ZstdSpans, they are not compressed if empty
+	SpansCompression string
+
+	// SpansExporters where the spans are sent, any of ExtensionSpansExporter,
+	// the default, StdoutSpansExporter, EdgeSpansExporter and the exporters
+	// registered by the imported packages, like OTLPSpansExporter
+	SpansExporters []string
+
+	// Exporters the exporters the spans are sent to on top of SpansExporters
+	Exporters []sdktrace.SpanExporter
+
+	// TracerProvider the provider of the user the Lumigo exporters are
+	// added to, instead of a provider set as the global one. Neither the
+	// global propagator nor the ID generator are set with it, even with
+	// PropagateXRay
+	TracerProvider *sdktrace.TracerProvider
+
+	// EdgeURL the URL the EdgeSpansExporter posts the spans to,
+	// the edge of the function region if empty
+	EdgeURL string
+
+	// EdgeTimeout the most time the EdgeSpansExporter takes to post
+	// the spans, 3 seconds if 0
+	EdgeTimeout time.Duration
 }
 
+const (
+	// DropOldestSpans removes the oldest span files to make room
+	DropOldestSpans = "drop_oldest"
+	// DropNewestSpans does not write spans which do not fit
+	DropNewestSpans = "drop_newest"
+)
+
+const (
+	// ExtensionSpansExporter writes the spans to files the lumigo
+	// extension sends
+	ExtensionSpansExporter = "extension"
+	// EdgeSpansExporter posts the spans straight to the Lumigo edge
+	EdgeSpansExporter = "edge"
+	// StdoutSpansExporter prints the spans in stdout
+	StdoutSpansExporter = "stdout"
+	// OTLPSpansExporter sends the spans to an OpenTelemetry collector,
+	// registered by the github.com/lumigo-io/go-tracer-beta/otlp package
+	OTLPSpansExporter = "otlp"
+)
+
+const (
+	defaultSpansMaxBytes = 100 << 20
+	defaultSpansMaxAge   = time.Hour
+)
+
 // cfg it's a public empty config
 var cfg Config
 
@@ -38,6 +161,7 @@ func init() {
 	viper.SetEnvPrefix("LUMIGO")
 	viper.SetDefault("ENABLED", true)
 	viper.SetDefault("DEBUG", false)
+	viper.SetDefault("PROPAGATE_XRAY", false)
 }
 
 func loadConfig(conf Config) error {
@@ -50,5 +174,256 @@ func loadConfig(conf Config) error {
 	cfg.enabled = viper.GetBool("ENABLED")
 	cfg.debug = viper.GetBool("DEBUG")
 	cfg.PrintStdout = conf.PrintStdout
+	cfg.InstrumentDefaultTransport = conf.InstrumentDefaultTransport
+	if viper.GetString("INSTRUMENT_DEFAULT_TRANSPORT") != "" {
+		cfg.InstrumentDefaultTransport = viper.GetBool("INSTRUMENT_DEFAULT_TRANSPORT")
+	}
+	cfg.PropagateXRay = conf.PropagateXRay || viper.GetBool("PROPAGATE_XRAY")
+
+	cfg.HTTPHeadersAllowlist = conf.HTTPHeadersAllowlist
+	if allowlist := viper.GetString("HTTP_HEADERS_ALLOWLIST"); allowlist != "" {
+		cfg.HTTPHeadersAllowlist = strings.Split(allowlist, ",")
+	}
+	cfg.HTTPHeadersDenylist = conf.HTTPHeadersDenylist
+	if denylist := viper.GetString("HTTP_HEADERS_DENYLIST"); denylist != "" {
+		cfg.HTTPHeadersDenylist = strings.Split(denylist, ",")
+	}
+	cfg.SecretMaskingRegex = getJSONList("SECRET_MASKING_REGEX", conf.SecretMaskingRegex)
+	cfg.secretMaskingRegexps = defaultSecretMaskingRegexps
+	if len(cfg.SecretMaskingRegex) > 0 {
+		cfg.secretMaskingRegexps = compileRegexList(cfg.SecretMaskingRegex)
+	}
+	headers := cfg.buildHeaderFilter()
+	cfg.headers = &headers
+
+	cfg.IgnoredDomains = getJSONList("IGNORED_DOMAINS", conf.IgnoredDomains)
+	cfg.ignoredDomainsRegexps = append(compileRegexList(cfg.IgnoredDomains), defaultIgnoredDomainsRegexps...)
+	cfg.IgnoredPaths = getJSONList("IGNORED_PATHS", conf.IgnoredPaths)
+	cfg.ignoredPathsRegexps = compileRegexList(cfg.IgnoredPaths)
+
+	cfg.DynamoDBKeys = conf.DynamoDBKeys
+	if value := viper.GetString("DYNAMODB_KEYS"); value != "" {
+		var keys map[string][]string
+		if err := json.Unmarshal([]byte(value), &keys); err != nil {
+			logger.WithError(err).Error("invalid LUMIGO_DYNAMODB_KEYS, expected a json object of lists")
+		} else {
+			cfg.DynamoDBKeys = keys
+		}
+	}
+	awsparser.SetKeySchemas(cfg.DynamoDBKeys)
+
+	cfg.SpansDir = conf.SpansDir
+	if dir := viper.GetString("SPANS_DIR"); dir != "" {
+		cfg.SpansDir = dir
+	}
+	cfg.SpansMaxBytes = conf.SpansMaxBytes
+	if viper.GetString("SPANS_MAX_BYTES") != "" {
+		cfg.SpansMaxBytes = viper.GetInt64("SPANS_MAX_BYTES")
+	}
+	cfg.SpansMaxFiles = conf.SpansMaxFiles
+	if viper.GetString("SPANS_MAX_FILES") != "" {
+		cfg.SpansMaxFiles = viper.GetInt("SPANS_MAX_FILES")
+	}
+	cfg.SpansOverflowPolicy = conf.SpansOverflowPolicy
+	if policy := viper.GetString("SPANS_OVERFLOW_POLICY"); policy != "" {
+		cfg.SpansOverflowPolicy = policy
+	}
+	cfg.SpansMaxAge = conf.SpansMaxAge
+	if viper.GetString("SPANS_MAX_AGE") != "" {
+		cfg.SpansMaxAge = viper.GetDuration("SPANS_MAX_AGE")
+	}
+	cfg.SpansCompression = conf.SpansCompression
+	if compression := viper.GetString("SPANS_COMPRESSION"); compression != "" {
+		cfg.SpansCompression = compression
+	}
+	cfg.SpansExporters = conf.SpansExporters
+	if exporters := viper.GetString("SPANS_EXPORTERS"); exporters != "" {
+		cfg.SpansExporters = strings.Split(exporters, ",")
+	}
+	cfg.Exporters = conf.Exporters
+	cfg.TracerProvider = conf.TracerProvider
+	if cfg.TracerProvider != nil && cfg.PropagateXRay {
+		logger.Warn("PropagateXRay does not apply to Config.TracerProvider, " +
+			"set the X-Ray propagator and ID generator on it and as the global propagator")
+	}
+	cfg.EdgeURL = conf.EdgeURL
+	if url := viper.GetString("EDGE_URL"); url != "" {
+		cfg.EdgeURL = url
+	}
+	cfg.EdgeTimeout = conf.EdgeTimeout
+	if viper.GetString("EDGE_TIMEOUT") != "" {
+		cfg.EdgeTimeout = viper.GetDuration("EDGE_TIMEOUT")
+	}
 	return cfg.validate()
 }
+
+// getJSONList reads an environment variable holding a json list,
+// falling back to the given list if it is unset or invalid
+func getJSONList(key string, fallback []string) []string {
+	value := viper.GetString(key)
+	if value == "" {
+		return fallback
+	}
+	var list []string
+	if err := json.Unmarshal([]byte(value), &list); err != nil {
+		logger.WithError(err).Errorf("invalid LUMIGO_%s, expected a json list", key)
+		return fallback
+	}
+	return list
+}
+
+// compileRegexList compiles case insensitive patterns matching
+// the whole string, skipping invalid ones
+func compileRegexList(patterns []string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile("(?i)^(?:" + pattern + ")$")
+		if err != nil {
+			logger.WithError(err).Errorf("invalid regex: %s", pattern)
+			continue
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled
+}
+
+// headerFilter returns the HTTP headers filter configured, which is
+// built once when the config is loaded
+func (cfg Config) headerFilter() headerFilter {
+	if cfg.headers != nil {
+		return *cfg.headers
+	}
+	return cfg.buildHeaderFilter()
+}
+
+// buildHeaderFilter builds the HTTP headers filter of the headers options
+func (cfg Config) buildHeaderFilter() headerFilter {
+	masking := cfg.secretMaskingRegexps
+	if masking == nil {
+		masking = defaultSecretMaskingRegexps
+	}
+	return newHeaderFilter(cfg.HTTPHeadersAllowlist, cfg.HTTPHeadersDenylist, masking)
+}
+
+// requestIgnorer returns the outgoing HTTP calls ignorer configured
+func (cfg Config) requestIgnorer() requestIgnorer {
+	domains := cfg.ignoredDomainsRegexps
+	if domains == nil {
+		domains = defaultIgnoredDomainsRegexps
+	}
+	return requestIgnorer{domains: domains, paths: cfg.ignoredPathsRegexps}
+}
+
+// spansDir returns the dir the span files are written to
+func (cfg Config) spansDir() string {
+	if cfg.SpansDir == "" {
+		return SPANS_DIR
+	}
+	return cfg.SpansDir
+}
+
+// spansQuota returns the limits of the spans dir configured
+func (cfg Config) spansQuota() spansQuota {
+	quota := spansQuota{
+		maxBytes: cfg.SpansMaxBytes,
+		maxFiles: cfg.SpansMaxFiles,
+		policy:   cfg.SpansOverflowPolicy,
+	}
+	switch {
+	case quota.maxBytes == 0:
+		quota.maxBytes = defaultSpansMaxBytes
+	case quota.maxBytes < 0:
+		quota.maxBytes = 0
+	}
+	if quota.maxFiles < 0 {
+		quota.maxFiles = 0
+	}
+	switch quota.policy {
+	case DropOldestSpans, DropNewestSpans:
+	case "":
+		quota.policy = DropOldestSpans
+	default:
+		logger.Errorf("invalid spans overflow policy: %s", quota.policy)
+		quota.policy = DropOldestSpans
+	}
+	return quota
+}
+
+// spansMaxAge returns the age after which span files are stale,
+// 0 if they never are
+func (cfg Config) spansMaxAge() time.Duration {
+	switch {
+	case cfg.SpansMaxAge == 0:
+		return defaultSpansMaxAge
+	case cfg.SpansMaxAge < 0:
+		return 0
+	}
+	return cfg.SpansMaxAge
+}
+
+// spansCompression returns the compression of the span files
+// configured, empty if they are not compressed
+func (cfg Config) spansCompression() string {
+	switch cfg.SpansCompression {
+	case "", GzipSpans, ZstdSpans:
+		return cfg.SpansCompression
+	}
+	logger.Errorf("invalid spans compression: %s", cfg.SpansCompression)
+	return ""
+}
+
+// spansExporters returns where the spans are sent, skipping the
+// invalid and repeated exporters
+func (cfg Config) spansExporters() []string {
+	exporters := make([]string, 0, len(cfg.SpansExporters))
+	seen := make(map[string]bool, len(cfg.SpansExporters))
+	for _, exporter := range cfg.SpansExporters {
+		exporter = strings.TrimSpace(exporter)
+		switch {
+		case exporter == ExtensionSpansExporter, exporter == StdoutSpansExporter, exporter == EdgeSpansExporter,
+			registeredSpansExporter(exporter) != nil:
+			if !seen[exporter] {
+				seen[exporter] = true
+				exporters = append(exporters, exporter)
+			}
+		case exporter == OTLPSpansExporter:
+			logger.Errorf("the %s spans exporter needs the github.com/lumigo-io/go-tracer-beta/otlp package to be imported", exporter)
+		default:
+			logger.Errorf("invalid spans exporter: %s", exporter)
+		}
+	}
+	if len(exporters) == 0 && !cfg.PrintStdout {
+		return []string{ExtensionSpansExporter}
+	}
+	// PrintStdout alone replaces the default exporter
+	if cfg.PrintStdout && !seen[StdoutSpansExporter] {
+		exporters = append(exporters, StdoutSpansExporter)
+	}
+	return exporters
+}
+
+// hasSpansExporter returns whether the spans are sent to exporter
+func (cfg Config) hasSpansExporter(exporter string) bool {
+	for _, e := range cfg.spansExporters() {
+		if e == exporter {
+			return true
+		}
+	}
+	return false
+}
+
+// edgeURL returns the URL the spans are posted to
+func (cfg Config) edgeURL() string {
+	if cfg.EdgeURL == "" {
+		return defaultEdgeURL()
+	}
+	return cfg.EdgeURL
+}
+
+// edgeTimeout returns the most time a POST of the spans takes
+func (cfg Config) edgeTimeout() time.Duration {
+	if cfg.EdgeTimeout <= 0 {
+		return defaultEdgeTimeout
+	}
+	return cfg.EdgeTimeout
+}
@@ -1,11 +1,15 @@
 package lumigotracer
 
 import (
+	"bytes"
+	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 type configTestSuite struct {
@@ -20,6 +24,23 @@ func (conf *configTestSuite) TearDownTest() {
 	os.Unsetenv("LUMIGO_TRACER_TOKEN")
 	os.Unsetenv("LUMIGO_DEBUG")
 	os.Unsetenv("LUMIGO_ENABLED")
+	os.Unsetenv("LUMIGO_HTTP_HEADERS_ALLOWLIST")
+	os.Unsetenv("LUMIGO_HTTP_HEADERS_DENYLIST")
+	os.Unsetenv("LUMIGO_SECRET_MASKING_REGEX")
+	os.Unsetenv("LUMIGO_IGNORED_DOMAINS")
+	os.Unsetenv("LUMIGO_IGNORED_PATHS")
+	os.Unsetenv("LUMIGO_INSTRUMENT_DEFAULT_TRANSPORT")
+	os.Unsetenv("LUMIGO_PROPAGATE_XRAY")
+	os.Unsetenv("LUMIGO_SPANS_DIR")
+	os.Unsetenv("LUMIGO_SPANS_MAX_BYTES")
+	os.Unsetenv("LUMIGO_SPANS_MAX_FILES")
+	os.Unsetenv("LUMIGO_SPANS_OVERFLOW_POLICY")
+	os.Unsetenv("LUMIGO_SPANS_MAX_AGE")
+	os.Unsetenv("LUMIGO_SPANS_COMPRESSION")
+	os.Unsetenv("LUMIGO_SPANS_EXPORTERS")
+	os.Unsetenv("LUMIGO_EDGE_URL")
+	os.Unsetenv("LUMIGO_EDGE_TIMEOUT")
+	os.Unsetenv("LUMIGO_DYNAMODB_KEYS")
 }
 
 func (conf *configTestSuite) TestConfigValidationMissingToken() {
@@ -47,3 +68,181 @@ func (conf *configTestSuite) TestConfigEnabledByDefault() {
 	assert.Equal(conf.T(), false, cfg.debug)
 	assert.Equal(conf.T(), true, cfg.enabled)
 }
+
+func (conf *configTestSuite) TestConfigHTTPHeadersEnvVariables() {
+	os.Setenv("LUMIGO_TRACER_TOKEN", "token")
+	os.Setenv("LUMIGO_HTTP_HEADERS_ALLOWLIST", "Via,Content-Type")
+	os.Setenv("LUMIGO_HTTP_HEADERS_DENYLIST", "Set-Cookie")
+	os.Setenv("LUMIGO_SECRET_MASKING_REGEX", `["x-custom-.*"]`)
+
+	err := loadConfig(Config{HTTPHeadersAllowlist: []string{"Accept"}})
+	assert.NoError(conf.T(), err)
+	assert.Equal(conf.T(), []string{"Via", "Content-Type"}, cfg.HTTPHeadersAllowlist)
+	assert.Equal(conf.T(), []string{"Set-Cookie"}, cfg.HTTPHeadersDenylist)
+	assert.Equal(conf.T(), []string{"x-custom-.*"}, cfg.SecretMaskingRegex)
+	assert.Len(conf.T(), cfg.secretMaskingRegexps, 1)
+
+	// the filter is built once with the config
+	assert.NotNil(conf.T(), cfg.headers)
+	assert.Equal(conf.T(), map[string]bool{"Via": true, "Content-Type": true}, cfg.headerFilter().allowlist)
+	assert.Equal(conf.T(), map[string]bool{"Set-Cookie": true}, cfg.headerFilter().denylist)
+}
+
+func (conf *configTestSuite) TestConfigSecretMaskingDefaults() {
+	os.Setenv("LUMIGO_TRACER_TOKEN", "token")
+
+	err := loadConfig(Config{})
+	assert.NoError(conf.T(), err)
+	assert.Empty(conf.T(), cfg.SecretMaskingRegex)
+	assert.Equal(conf.T(), defaultSecretMaskingRegexps, cfg.secretMaskingRegexps)
+}
+
+func (conf *configTestSuite) TestConfigIgnoredEnvVariables() {
+	os.Setenv("LUMIGO_TRACER_TOKEN", "token")
+	os.Setenv("LUMIGO_IGNORED_DOMAINS", `["169\\.254\\.169\\.254"]`)
+	os.Setenv("LUMIGO_IGNORED_PATHS", `not a json list`)
+
+	err := loadConfig(Config{IgnoredPaths: []string{"/health"}})
+	assert.NoError(conf.T(), err)
+	assert.Equal(conf.T(), []string{`169\.254\.169\.254`}, cfg.IgnoredDomains)
+	assert.Len(conf.T(), cfg.ignoredDomainsRegexps, 1+len(defaultIgnoredDomainsRegexps))
+	assert.Equal(conf.T(), []string{"/health"}, cfg.IgnoredPaths)
+	assert.Len(conf.T(), cfg.ignoredPathsRegexps, 1)
+}
+
+func (conf *configTestSuite) TestConfigInstrumentDefaultTransport() {
+	os.Setenv("LUMIGO_TRACER_TOKEN", "token")
+
+	err := loadConfig(Config{})
+	assert.NoError(conf.T(), err)
+	assert.False(conf.T(), cfg.InstrumentDefaultTransport)
+
+	os.Setenv("LUMIGO_INSTRUMENT_DEFAULT_TRANSPORT", "true")
+	err = loadConfig(Config{})
+	assert.NoError(conf.T(), err)
+	assert.True(conf.T(), cfg.InstrumentDefaultTransport)
+
+	// the environment opts out of the instrumentation of the config
+	os.Setenv("LUMIGO_INSTRUMENT_DEFAULT_TRANSPORT", "false")
+	err = loadConfig(Config{InstrumentDefaultTransport: true})
+	assert.NoError(conf.T(), err)
+	assert.False(conf.T(), cfg.InstrumentDefaultTransport)
+}
+
+func (conf *configTestSuite) TestConfigPropagateXRay() {
+	os.Setenv("LUMIGO_TRACER_TOKEN", "token")
+
+	assert.NoError(conf.T(), loadConfig(Config{}))
+	assert.False(conf.T(), cfg.PropagateXRay)
+
+	os.Setenv("LUMIGO_PROPAGATE_XRAY", "true")
+	assert.NoError(conf.T(), loadConfig(Config{}))
+	assert.True(conf.T(), cfg.PropagateXRay)
+}
+
+func (conf *configTestSuite) TestConfigPropagateXRayWithTracerProvider() {
+	os.Setenv("LUMIGO_TRACER_TOKEN", "token")
+	os.Setenv("LUMIGO_PROPAGATE_XRAY", "true")
+	var buf bytes.Buffer
+	logger.Out = &buf
+	defer func() { logger.Out = ioutil.Discard }()
+
+	assert.NoError(conf.T(), loadConfig(Config{}))
+	assert.Empty(conf.T(), buf.String())
+
+	// the provider of the user is not set up for X-Ray
+	assert.NoError(conf.T(), loadConfig(Config{TracerProvider: sdktrace.NewTracerProvider()}))
+	assert.Contains(conf.T(), buf.String(), "PropagateXRay does not apply to Config.TracerProvider")
+}
+
+func (conf *configTestSuite) TestConfigSpansDirDefaults() {
+	os.Setenv("LUMIGO_TRACER_TOKEN", "token")
+
+	assert.NoError(conf.T(), loadConfig(Config{}))
+	assert.Equal(conf.T(), SPANS_DIR, cfg.spansDir())
+	assert.Equal(conf.T(), spansQuota{maxBytes: defaultSpansMaxBytes, policy: DropOldestSpans}, cfg.spansQuota())
+	assert.Equal(conf.T(), defaultSpansMaxAge, cfg.spansMaxAge())
+
+	assert.NoError(conf.T(), loadConfig(Config{SpansMaxBytes: -1, SpansMaxFiles: -1, SpansMaxAge: -1, SpansOverflowPolicy: "drop_all"}))
+	assert.Equal(conf.T(), spansQuota{policy: DropOldestSpans}, cfg.spansQuota())
+	assert.Equal(conf.T(), time.Duration(0), cfg.spansMaxAge())
+}
+
+func (conf *configTestSuite) TestConfigSpansDirEnvVariables() {
+	os.Setenv("LUMIGO_TRACER_TOKEN", "token")
+	os.Setenv("LUMIGO_SPANS_DIR", "/tmp/spans")
+	os.Setenv("LUMIGO_SPANS_MAX_BYTES", "1024")
+	os.Setenv("LUMIGO_SPANS_MAX_FILES", "10")
+	os.Setenv("LUMIGO_SPANS_OVERFLOW_POLICY", DropNewestSpans)
+	os.Setenv("LUMIGO_SPANS_MAX_AGE", "10m")
+
+	assert.NoError(conf.T(), loadConfig(Config{SpansDir: "/tmp/other", SpansMaxFiles: 5}))
+	assert.Equal(conf.T(), "/tmp/spans", cfg.spansDir())
+	assert.Equal(conf.T(), spansQuota{maxBytes: 1024, maxFiles: 10, policy: DropNewestSpans}, cfg.spansQuota())
+	assert.Equal(conf.T(), 10*time.Minute, cfg.spansMaxAge())
+}
+
+func (conf *configTestSuite) TestConfigSpansCompression() {
+	os.Setenv("LUMIGO_TRACER_TOKEN", "token")
+
+	assert.NoError(conf.T(), loadConfig(Config{}))
+	assert.Equal(conf.T(), "", cfg.spansCompression())
+
+	assert.NoError(conf.T(), loadConfig(Config{SpansCompression: "brotli"}))
+	assert.Equal(conf.T(), "", cfg.spansCompression())
+
+	os.Setenv("LUMIGO_SPANS_COMPRESSION", ZstdSpans)
+	assert.NoError(conf.T(), loadConfig(Config{SpansCompression: GzipSpans}))
+	assert.Equal(conf.T(), ZstdSpans, cfg.spansCompression())
+}
+
+func (conf *configTestSuite) TestConfigSpansExporter() {
+	os.Setenv("LUMIGO_TRACER_TOKEN", "token")
+	os.Setenv("AWS_REGION", "eu-west-1")
+
+	assert.NoError(conf.T(), loadConfig(Config{SpansExporters: []string{"kafka"}}))
+	assert.Equal(conf.T(), []string{ExtensionSpansExporter}, cfg.spansExporters())
+	// the otlp exporter is registered by the otlp package
+	assert.NoError(conf.T(), loadConfig(Config{SpansExporters: []string{OTLPSpansExporter}}))
+	assert.Equal(conf.T(), []string{ExtensionSpansExporter}, cfg.spansExporters())
+	assert.Equal(conf.T(), "https://eu-west-1.lumigo-tracer-edge.golumigo.com/api/spans", cfg.edgeURL())
+	assert.Equal(conf.T(), defaultEdgeTimeout, cfg.edgeTimeout())
+
+	os.Setenv("LUMIGO_SPANS_EXPORTERS", EdgeSpansExporter)
+	os.Setenv("LUMIGO_EDGE_URL", "http://localhost:8080/api/spans")
+	os.Setenv("LUMIGO_EDGE_TIMEOUT", "500ms")
+	assert.NoError(conf.T(), loadConfig(Config{}))
+	assert.Equal(conf.T(), []string{EdgeSpansExporter}, cfg.spansExporters())
+	assert.Equal(conf.T(), "http://localhost:8080/api/spans", cfg.edgeURL())
+	assert.Equal(conf.T(), 500*time.Millisecond, cfg.edgeTimeout())
+}
+
+func (conf *configTestSuite) TestConfigSpansExporters() {
+	os.Setenv("LUMIGO_TRACER_TOKEN", "token")
+
+	assert.NoError(conf.T(), loadConfig(Config{PrintStdout: true}))
+	assert.Equal(conf.T(), []string{StdoutSpansExporter}, cfg.spansExporters())
+	assert.False(conf.T(), cfg.hasSpansExporter(ExtensionSpansExporter))
+
+	os.Setenv("LUMIGO_SPANS_EXPORTERS", "extension, edge,kafka,extension")
+	assert.NoError(conf.T(), loadConfig(Config{PrintStdout: true}))
+	assert.Equal(conf.T(), []string{ExtensionSpansExporter, EdgeSpansExporter, StdoutSpansExporter}, cfg.spansExporters())
+	assert.True(conf.T(), cfg.hasSpansExporter(ExtensionSpansExporter))
+}
+
+func (conf *configTestSuite) TestConfigDynamoDBKeys() {
+	os.Setenv("LUMIGO_TRACER_TOKEN", "token")
+	keys := map[string][]string{"orders": {"id"}}
+
+	assert.NoError(conf.T(), loadConfig(Config{DynamoDBKeys: keys}))
+	assert.Equal(conf.T(), keys, cfg.DynamoDBKeys)
+
+	os.Setenv("LUMIGO_DYNAMODB_KEYS", `{"users":["tenant","id"]}`)
+	assert.NoError(conf.T(), loadConfig(Config{DynamoDBKeys: keys}))
+	assert.Equal(conf.T(), map[string][]string{"users": {"tenant", "id"}}, cfg.DynamoDBKeys)
+	assert.Equal(conf.T(), map[string][]string{"orders": {"id"}}, keys)
+
+	os.Setenv("LUMIGO_DYNAMODB_KEYS", `["id"]`)
+	assert.NoError(conf.T(), loadConfig(Config{DynamoDBKeys: keys}))
+	assert.Equal(conf.T(), keys, cfg.DynamoDBKeys)
+}
@@ -0,0 +1,220 @@
+package lumigotracer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lumigo-io/go-tracer-beta/internal/telemetry"
+	"github.com/lumigo-io/go-tracer-beta/internal/transform"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	defaultEdgeTimeout = 3 * time.Second
+
+	// edgeMaxAttempts the most POSTs of a batch of spans
+	edgeMaxAttempts = 3
+
+	// edgeRetryBackoff the wait before a retry, times the attempts made
+	edgeRetryBackoff = 100 * time.Millisecond
+
+	// exportDeadlineMargin the invocation time kept for the handler
+	// response, the spans are not sent past it
+	exportDeadlineMargin = 200 * time.Millisecond
+)
+
+// defaultEdgeURL returns the Lumigo edge of the function region
+func defaultEdgeURL() string {
+	return fmt.Sprintf("https://%s.lumigo-tracer-edge.golumigo.com/api/spans", os.Getenv("AWS_REGION"))
+}
+
+// edgeExporter posts the spans straight to the Lumigo edge,
+// without the lumigo extension
+type edgeExporter struct {
+	context context.Context
+	logger  logrus.FieldLogger
+	url     string
+	token   string
+	timeout time.Duration
+	client  *http.Client
+
+	stoppedMu sync.RWMutex
+	stopped   bool
+}
+
+var (
+	edgeClientOnce sync.Once
+	edgeClient     *http.Client
+)
+
+// newEdgeExporter creates an edgeExporter posting to url, each POST
+// takes at most timeout and never past the invocation deadline of ctx
+func newEdgeExporter(ctx context.Context, logger logrus.FieldLogger, url, token string, timeout time.Duration) *edgeExporter {
+	return &edgeExporter{
+		context: ctx,
+		logger:  logger,
+		url:     url,
+		token:   token,
+		timeout: timeout,
+		client:  sharedEdgeClient(),
+	}
+}
+
+// sharedEdgeClient returns the client of the edge exporters, created
+// once per container so that the invocations reuse its connections
+func sharedEdgeClient() *http.Client {
+	edgeClientOnce.Do(func() {
+		edgeClient = &http.Client{Transport: newEdgeTransport()}
+	})
+	return edgeClient
+}
+
+// newEdgeTransport returns a transport of its own, http.DefaultTransport
+// may be instrumented and tracing the exports would create more spans
+func newEdgeTransport() *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// ExportSpans posts the spans to the edge in a gzipped json batch,
+// retrying failed attempts while the invocation has time left
+func (e *edgeExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if e == nil {
+		return nil
+	}
+	e.stoppedMu.RLock()
+	stopped := e.stopped
+	e.stoppedMu.RUnlock()
+	if stopped || len(spans) == 0 {
+		return nil
+	}
+
+	lumigoSpans := make([]telemetry.Span, 0, len(spans))
+	for _, span := range spans {
+		lumigoSpans = append(lumigoSpans, transform.NewMapper(e.context, span, logger).Transform())
+	}
+	body, err := gzipSpans(lumigoSpans)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode spans")
+	}
+
+	timeout, ok := exportTimeout(e.context, e.timeout)
+	if !ok {
+		return errors.Errorf("no invocation time left to send %d spans", len(lumigoSpans))
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for attempt := 1; ; attempt++ {
+		retry, err := e.post(ctx, body)
+		if err == nil {
+			e.logger.Infof("sent %d spans to the edge", len(lumigoSpans))
+			return nil
+		}
+		if !retry || attempt == edgeMaxAttempts {
+			return errors.Wrapf(err, "failed to send spans to the edge after %d attempts", attempt)
+		}
+		e.logger.WithError(err).Warnf("failed to send spans to the edge, attempt %d", attempt)
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(err, "failed to send spans to the edge before the timeout")
+		case <-time.After(time.Duration(attempt) * edgeRetryBackoff):
+		}
+	}
+}
+
+// post sends the body once and returns whether a failure is worth
+// a retry, which server and network errors are
+func (e *edgeExporter) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return false, errors.Wrap(err, "failed to create edge request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Authorization", e.token)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body) // nolint
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+		return true, errors.Errorf("edge responded %s", resp.Status)
+	case resp.StatusCode >= http.StatusMultipleChoices:
+		return false, errors.Errorf("edge responded %s", resp.Status)
+	}
+	return false, nil
+}
+
+// Shutdown stops the exporter, the connections of the shared client
+// are kept for the next invocations
+func (e *edgeExporter) Shutdown(ctx context.Context) error {
+	e.stoppedMu.Lock()
+	e.stopped = true
+	e.stoppedMu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+	return nil
+}
+
+// exportTimeout bounds timeout by the invocation deadline of ctx,
+// it returns false if there is no time left to export
+func exportTimeout(ctx context.Context, timeout time.Duration) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return timeout, true
+	}
+	remaining := time.Until(deadline) - exportDeadlineMargin
+	if remaining <= 0 {
+		return 0, false
+	}
+	if remaining < timeout {
+		return remaining, true
+	}
+	return timeout, true
+}
+
+// gzipSpans returns the gzipped json spans
+func gzipSpans(spans []telemetry.Span) ([]byte, error) {
+	var body bytes.Buffer
+	gzipWriter, err := gzip.NewWriterLevel(&body, gzip.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.NewEncoder(gzipWriter).Encode(spans); err != nil {
+		return nil, err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+	return body.Bytes(), nil
+}
@@ -0,0 +1,147 @@
+package lumigotracer
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/lumigo-io/go-tracer-beta/internal/telemetry"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func testEdgeSpans() []sdktrace.ReadOnlySpan {
+	return []sdktrace.ReadOnlySpan{
+		(&tracetest.SpanStub{Name: "first", StartTime: time.Now(), EndTime: time.Now()}).Snapshot(),
+		(&tracetest.SpanStub{Name: "second", StartTime: time.Now(), EndTime: time.Now()}).Snapshot(),
+	}
+}
+
+func TestEdgeExporterExportSpans(t *testing.T) {
+	logger.Out = ioutil.Discard
+	var received []telemetry.Span
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "t_123", r.Header.Get("Authorization"))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+
+		body, err := gzip.NewReader(r.Body)
+		assert.NoError(t, err)
+		assert.NoError(t, json.NewDecoder(body).Decode(&received))
+	}))
+	defer ts.Close()
+
+	ctx := lambdacontext.NewContext(context.Background(), &mockLambdaContext)
+	exp := newEdgeExporter(ctx, logger, ts.URL, "t_123", time.Second)
+	assert.NoError(t, exp.ExportSpans(context.Background(), testEdgeSpans()))
+	assert.Len(t, received, 2)
+	assert.Equal(t, "account-id", received[0].Account)
+
+	// the exporters of the invocations share the client and its connections
+	assert.Same(t, exp.client, newEdgeExporter(ctx, logger, ts.URL, "t_123", time.Second).client)
+
+	assert.NoError(t, exp.Shutdown(context.Background()))
+	received = nil
+	assert.NoError(t, exp.ExportSpans(context.Background(), testEdgeSpans()))
+	assert.Nil(t, received)
+}
+
+func TestEdgeExporterRetries(t *testing.T) {
+	logger.Out = ioutil.Discard
+
+	testcases := []struct {
+		testname         string
+		statuses         []int
+		expectedAttempts int32
+		expectedError    bool
+	}{
+		{
+			testname:         "retries server errors",
+			statuses:         []int{http.StatusInternalServerError, http.StatusTooManyRequests, http.StatusOK},
+			expectedAttempts: 3,
+		},
+		{
+			testname:         "gives up after the last attempt",
+			statuses:         []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway, http.StatusOK},
+			expectedAttempts: edgeMaxAttempts,
+			expectedError:    true,
+		},
+		{
+			testname:         "does not retry client errors",
+			statuses:         []int{http.StatusUnauthorized, http.StatusOK},
+			expectedAttempts: 1,
+			expectedError:    true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testname, func(t *testing.T) {
+			var attempts int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempt := atomic.AddInt32(&attempts, 1)
+				w.WriteHeader(tc.statuses[attempt-1])
+			}))
+			defer ts.Close()
+
+			exp := newEdgeExporter(context.Background(), logger, ts.URL, "t_123", time.Second)
+			err := exp.ExportSpans(context.Background(), testEdgeSpans())
+			assert.Equal(t, tc.expectedError, err != nil)
+			assert.Equal(t, tc.expectedAttempts, atomic.LoadInt32(&attempts))
+		})
+	}
+}
+
+func TestEdgeExporterInvocationDeadline(t *testing.T) {
+	logger.Out = ioutil.Discard
+	var attempts int32
+	stop := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		<-stop
+	}))
+	defer ts.Close()
+	defer close(stop)
+
+	// the POST is cut short by the invocation deadline, not the timeout
+	ctx, cancel := context.WithTimeout(context.Background(), exportDeadlineMargin+300*time.Millisecond)
+	defer cancel()
+	exp := newEdgeExporter(ctx, logger, ts.URL, "t_123", time.Minute)
+	start := time.Now()
+	assert.Error(t, exp.ExportSpans(context.Background(), testEdgeSpans()))
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+
+	// no request is sent once the deadline is within the margin
+	ctx, cancel = context.WithTimeout(context.Background(), exportDeadlineMargin/2)
+	defer cancel()
+	exp = newEdgeExporter(ctx, logger, ts.URL, "t_123", time.Minute)
+	assert.Error(t, exp.ExportSpans(context.Background(), testEdgeSpans()))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
+
+func TestEdgeExporterNotTraced(t *testing.T) {
+	logger.Out = ioutil.Discard
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	sr := tracetest.NewSpanRecorder()
+	previousProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	defer otel.SetTracerProvider(previousProvider)
+	restore := InstrumentDefaultTransport()
+	defer restore()
+
+	exp := newEdgeExporter(context.Background(), logger, ts.URL, "t_123", time.Second)
+	assert.NoError(t, exp.ExportSpans(context.Background(), testEdgeSpans()))
+	assert.Empty(t, sr.Ended())
+}
@@ -1,11 +1,14 @@
 package lumigotracer
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/lumigo-io/go-tracer-beta/internal/telemetry"
 	"github.com/lumigo-io/go-tracer-beta/internal/transform"
@@ -15,10 +18,22 @@ import (
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
+// spansQuota the limits of the span files kept in the spans dir,
+// a zero limit is unlimited
+type spansQuota struct {
+	maxBytes int64
+	maxFiles int
+	policy   string
+}
+
 // Exporter exports OpenTelemetry data to Lumigo.
 type Exporter struct {
 	context   context.Context
 	logger    logrus.FieldLogger
+	dir       string
+	quota     spansQuota
+	lastID    ksuid.KSUID
+	encoder   *spansEncoder
 	encoderMu sync.Mutex
 
 	stoppedMu sync.RWMutex
@@ -26,10 +41,13 @@ type Exporter struct {
 }
 
 // newExporter creates an Exporter with the passed options.
-func newExporter(ctx context.Context, logger logrus.FieldLogger) (*Exporter, error) {
+func newExporter(ctx context.Context, logger logrus.FieldLogger, dir string, quota spansQuota, compression string) (*Exporter, error) {
 	return &Exporter{
 		logger:  logger,
 		context: ctx,
+		dir:     dir,
+		quota:   quota,
+		encoder: newSpansEncoder(compression),
 	}, nil
 }
 
@@ -57,7 +75,7 @@ func (e *Exporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpa
 		lumigoSpan := mapper.Transform()
 		if telemetry.IsStartSpan(span) {
 			e.logger.Info("writing start span")
-			if err := writeSpan([]telemetry.Span{lumigoSpan}, true); err != nil {
+			if err := e.writeSpan([]telemetry.Span{lumigoSpan}, true); err != nil {
 				return errors.Wrap(err, "failed to store startSpan")
 			}
 			continue
@@ -69,7 +87,7 @@ func (e *Exporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpa
 		return nil
 	}
 	e.logger.Info("writing end span")
-	if err := writeSpan(lumigoSpans, false); err != nil {
+	if err := e.writeSpan(lumigoSpans, false); err != nil {
 		return errors.Wrap(err, "failed to store endSpan")
 	}
 	return nil
@@ -90,20 +108,167 @@ func (e *Exporter) Shutdown(ctx context.Context) error {
 	return nil
 }
 
-func writeSpan(spans []telemetry.Span, isStart bool) error {
+// writeSpan writes the spans to a new file in the spans dir, unless
+// it is full and the quota policy drops the newest spans
+func (e *Exporter) writeSpan(spans []telemetry.Span, isStart bool) error {
+	var data bytes.Buffer
+	if err := e.encoder.encode(&data, spans); err != nil {
+		return errors.Wrap(err, "failed to encode spans")
+	}
+	if !e.reserve(int64(data.Len())) {
+		e.logger.Warnf("spans dir is full, dropping %d spans", len(spans))
+		return nil
+	}
+
 	var file string
 	if isStart {
-		file = fmt.Sprintf("/tmp/lumigo-spans/%s_span", ksuid.New())
+		file = fmt.Sprintf("%s/%s_span%s", e.dir, e.nextID(), e.encoder.suffix())
 	} else {
-		file = fmt.Sprintf("/tmp/lumigo-spans/%s_end", ksuid.New())
+		file = fmt.Sprintf("%s/%s_end%s", e.dir, e.nextID(), e.encoder.suffix())
+	}
+	return writeFileAtomic(file, func(w io.Writer) error {
+		_, err := data.WriteTo(w)
+		return err
+	})
+}
+
+// nextID returns a ksuid greater than the previous one, the random
+// payloads of ksuids of the same second are not ordered
+func (e *Exporter) nextID() ksuid.KSUID {
+	id := ksuid.New()
+	if ksuid.Compare(id, e.lastID) <= 0 {
+		id = e.lastID.Next()
+	}
+	e.lastID = id
+	return id
+}
+
+// reserve makes room for a span file of size bytes in the spans dir,
+// removing the oldest files if the quota policy allows it, and
+// returns whether the file fits
+func (e *Exporter) reserve(size int64) bool {
+	if e.quota.maxBytes == 0 && e.quota.maxFiles == 0 {
+		return true
+	}
+	if e.quota.maxBytes > 0 && size > e.quota.maxBytes {
+		return false
+	}
+	files, err := spanFiles(e.dir)
+	if err != nil {
+		e.logger.WithError(err).Error("failed to list span files")
+		return true
+	}
+	var total int64
+	for _, file := range files {
+		total += file.Size()
+	}
+	fits := func() bool {
+		return (e.quota.maxFiles == 0 || len(files) < e.quota.maxFiles) &&
+			(e.quota.maxBytes == 0 || total+size <= e.quota.maxBytes)
+	}
+	for !fits() {
+		if e.quota.policy != DropOldestSpans || len(files) == 0 {
+			return false
+		}
+		oldest := files[0]
+		if err := os.Remove(filepath.Join(e.dir, oldest.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			e.logger.WithError(err).Error("failed to remove oldest span file")
+			return false
+		}
+		e.logger.Warnf("spans dir is full, removed span file: %s", oldest.Name())
+		files = files[1:]
+		total -= oldest.Size()
+	}
+	return true
+}
+
+// spanFiles returns the span files of dir from the oldest to the newest,
+// the names start with a ksuid so they sort by creation time
+func spanFiles(dir string) ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, info)
+	}
+	return files, nil
+}
+
+// removeStaleSpans removes the files of dir not modified in maxAge,
+// and the temp files of interrupted writes
+func removeStaleSpans(dir string, maxAge time.Duration, logger logrus.FieldLogger) {
+	if maxAge <= 0 {
+		return
 	}
-	writer, err := os.Create(file)
+	deadline := time.Now().Add(-maxAge)
+	for _, d := range []string{dir, tempDir(dir)} {
+		entries, err := os.ReadDir(d)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				logger.WithError(err).Error("failed to list stale span files")
+			}
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil || !info.ModTime().Before(deadline) {
+				continue
+			}
+			if err := os.Remove(filepath.Join(d, entry.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+				logger.WithError(err).Errorf("failed to remove stale span file: %s", entry.Name())
+			}
+		}
+	}
+}
+
+// tempDir the dir of the temp files of the span files of dir, a sibling
+// so that renames stay on its filesystem and it holds only span files
+func tempDir(dir string) string {
+	return filepath.Clean(dir) + ".tmp"
+}
+
+// writeFileAtomic writes a file through a temp file in the sibling temp
+// dir, on the same filesystem, which is synced, closed and renamed in
+// place, so that readers of the dir never see a partially written file
+func writeFileAtomic(file string, write func(io.Writer) error) (err error) {
+	dir := tempDir(filepath.Dir(file))
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return errors.Wrapf(err, "failed to create dir: %s", dir)
+	}
+	tmp, err := os.CreateTemp(dir, fmt.Sprintf("%s.*.tmp", filepath.Base(file)))
 	if err != nil {
 		return errors.Wrapf(err, "failed to create span data store: %s", file)
 	}
-	enc := json.NewEncoder(writer)
-	if err := enc.Encode(spans); err != nil {
+	defer func() {
+		if err != nil {
+			tmp.Close()           // nolint
+			os.Remove(tmp.Name()) // nolint
+		}
+	}()
+
+	if err := write(tmp); err != nil {
 		return errors.Wrapf(err, "failed to write span in data store: %s", file)
 	}
+	if err := tmp.Sync(); err != nil {
+		return errors.Wrapf(err, "failed to sync span data store: %s", file)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrapf(err, "failed to close span data store: %s", file)
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return errors.Wrapf(err, "failed to rename span data store: %s", file)
+	}
 	return nil
 }
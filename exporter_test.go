@@ -3,10 +3,14 @@ package lumigotracer
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -107,10 +111,11 @@ func (e *exporterTestSuite) TestExportSpans() {
 	}
 
 	testContext := lambdacontext.NewContext(context.Background(), &mockLambdaContext)
-	exp, err := createExporter(false, testContext, logger)
+	exporters, err := createExporters(Config{}, testContext, logger)
 	assert.NoError(e.T(), err)
+	assert.Len(e.T(), exporters, 1)
 
-	err = exp.ExportSpans(context.Background(), []trace.ReadOnlySpan{
+	err = exporters[0].ExportSpans(context.Background(), []trace.ReadOnlySpan{
 		startSpan.Snapshot(),
 		endSpan.Snapshot(),
 	})
@@ -178,3 +183,184 @@ func deleteAllFiles() error {
 	}
 	return nil
 }
+
+func TestWriteFileAtomicInterrupted(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "1_end")
+
+	err := writeFileAtomic(file, func(w io.Writer) error {
+		if _, err := w.Write([]byte(`[{"id":"1","name":`)); err != nil {
+			return err
+		}
+		return errors.New("interrupted")
+	})
+	assert.Error(t, err)
+
+	// neither the partial file nor the temp file is left behind
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+	files, err = ioutil.ReadDir(tempDir(dir))
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestWriteFileAtomicConcurrentReaders(t *testing.T) {
+	dir := t.TempDir()
+	spans := make([]telemetry.Span, 50)
+	for i := range spans {
+		spans[i] = telemetry.Span{ID: fmt.Sprint(i), Event: strings.Repeat("e", 1024)}
+	}
+	content, err := json.Marshal(spans)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			err := writeFileAtomic(filepath.Join(dir, fmt.Sprintf("%d_end", i)), func(w io.Writer) error {
+				// write in chunks so that a reader may catch a partial write
+				for start := 0; start < len(content); start += 4096 {
+					end := start + 4096
+					if end > len(content) {
+						end = len(content)
+					}
+					if _, err := w.Write(content[start:end]); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			assert.NoError(t, err)
+		}
+	}()
+
+	for reading := true; reading; {
+		select {
+		case <-done:
+			reading = false
+		default:
+		}
+		files, err := ioutil.ReadDir(dir)
+		assert.NoError(t, err)
+		for _, file := range files {
+			data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+			assert.NoError(t, err)
+			assert.True(t, json.Valid(data), "partial span file %s", file.Name())
+		}
+	}
+	wg.Wait()
+
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 50)
+}
+
+func writeTestSpans(t *testing.T, exp *Exporter, count int) {
+	for i := 0; i < count; i++ {
+		err := exp.writeSpan([]telemetry.Span{{ID: fmt.Sprint(i)}}, false)
+		assert.NoError(t, err)
+	}
+}
+
+func TestExporterSpansQuota(t *testing.T) {
+	logger.Out = ioutil.Discard
+	encoded, err := json.Marshal([]telemetry.Span{{ID: "0"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spanSize := int64(len(encoded) + len("\n"))
+
+	testcases := []struct {
+		testname string
+		quota    spansQuota
+		expected []string
+	}{
+		{
+			testname: "unlimited",
+			expected: []string{"0", "1", "2", "3", "4"},
+		},
+		{
+			testname: "drop oldest by count",
+			quota:    spansQuota{maxFiles: 3, policy: DropOldestSpans},
+			expected: []string{"2", "3", "4"},
+		},
+		{
+			testname: "drop newest by count",
+			quota:    spansQuota{maxFiles: 3, policy: DropNewestSpans},
+			expected: []string{"0", "1", "2"},
+		},
+		{
+			testname: "drop oldest by size",
+			quota:    spansQuota{maxBytes: 2 * spanSize, policy: DropOldestSpans},
+			expected: []string{"3", "4"},
+		},
+		{
+			testname: "drop newest by size",
+			quota:    spansQuota{maxBytes: 2 * spanSize, policy: DropNewestSpans},
+			expected: []string{"0", "1"},
+		},
+		{
+			testname: "larger than the quota",
+			quota:    spansQuota{maxBytes: spanSize - 1, policy: DropOldestSpans},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testname, func(t *testing.T) {
+			dir := t.TempDir()
+			exp, err := newExporter(context.Background(), logger, dir, tc.quota, "")
+			assert.NoError(t, err)
+			writeTestSpans(t, exp, 5)
+
+			files, err := spanFiles(dir)
+			assert.NoError(t, err)
+			ids := []string{}
+			for _, file := range files {
+				var spans []telemetry.Span
+				content, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+				assert.NoError(t, err)
+				assert.NoError(t, json.Unmarshal(content, &spans))
+				ids = append(ids, spans[0].ID)
+			}
+			if tc.expected == nil {
+				tc.expected = []string{}
+			}
+			assert.Equal(t, tc.expected, ids)
+		})
+	}
+}
+
+func TestRemoveStaleSpans(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-2 * time.Hour)
+	for _, name := range []string{"1_end", "3_span"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte("[]"), 0600))
+	}
+	assert.NoError(t, os.Mkdir(tempDir(dir), os.ModePerm))
+	tmp := filepath.Join(tempDir(dir), "2_end.123.tmp")
+	assert.NoError(t, ioutil.WriteFile(tmp, []byte("[]"), 0600))
+	assert.NoError(t, os.Chtimes(filepath.Join(dir, "1_end"), old, old))
+	assert.NoError(t, os.Chtimes(tmp, old, old))
+
+	removeStaleSpans(dir, 0, logger)
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+	assert.FileExists(t, tmp)
+
+	removeStaleSpans(dir, time.Hour, logger)
+	files, err = ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, "3_span", files[0].Name())
+	assert.NoFileExists(t, tmp)
+
+	// a missing dir is not an error
+	removeStaleSpans(filepath.Join(dir, "missing"), time.Hour, logger)
+}
@@ -0,0 +1,133 @@
+package lumigotracer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// fanOutProcessor sends the spans to several exporters, each through a
+// processor of its own. The batchedExporters are exported from a batch
+// processor so that a slow or failing one does not hold back the others
+type fanOutProcessor struct {
+	logger     logrus.FieldLogger
+	processors []sdktrace.SpanProcessor
+}
+
+// batchedExporter an exporter sending the spans over the network, which
+// is given the spans in batches in the background. The other exporters,
+// like the one writing the span files the extension reads, export every
+// span as it ends
+type batchedExporter struct {
+	sdktrace.SpanExporter
+}
+
+// newFanOutProcessor creates a fanOutProcessor sending to exporters
+func newFanOutProcessor(logger logrus.FieldLogger, exporters ...sdktrace.SpanExporter) *fanOutProcessor {
+	processors := make([]sdktrace.SpanProcessor, 0, len(exporters))
+	for _, exporter := range exporters {
+		if batched, ok := exporter.(batchedExporter); ok {
+			processors = append(processors, sdktrace.NewBatchSpanProcessor(guardedExporter{logger: logger, exporter: batched.SpanExporter}))
+			continue
+		}
+		processors = append(processors, sdktrace.NewSimpleSpanProcessor(guardedExporter{logger: logger, exporter: exporter}))
+	}
+	return &fanOutProcessor{
+		logger:     logger,
+		processors: processors,
+	}
+}
+
+// OnStart does nothing, the spans are exported once they end
+func (f *fanOutProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {}
+
+// OnEnd exports the span to the synchronous exporters and queues it for
+// the batched ones
+func (f *fanOutProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	for _, processor := range f.processors {
+		processor.OnEnd(s)
+	}
+}
+
+// ForceFlush exports the queued spans of every exporter concurrently,
+// an exporter which does not finish before ctx is done is left behind
+func (f *fanOutProcessor) ForceFlush(ctx context.Context) error {
+	return f.forEach(func(processor sdktrace.SpanProcessor) error {
+		return processor.ForceFlush(ctx)
+	})
+}
+
+// Shutdown exports the queued spans and stops every exporter
+func (f *fanOutProcessor) Shutdown(ctx context.Context) error {
+	return f.forEach(func(processor sdktrace.SpanProcessor) error {
+		return processor.Shutdown(ctx)
+	})
+}
+
+// forEach calls fn with every processor concurrently and waits for them
+func (f *fanOutProcessor) forEach(fn func(sdktrace.SpanProcessor) error) error {
+	errs := make([]error, len(f.processors))
+	var wg sync.WaitGroup
+	for i, processor := range f.processors {
+		wg.Add(1)
+		go func(i int, processor sdktrace.SpanProcessor) {
+			defer wg.Done()
+			errs[i] = fn(processor)
+		}(i, processor)
+	}
+	wg.Wait()
+
+	var failed exportersError
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return failed
+}
+
+// guardedExporter logs the errors of an exporter and recovers from its
+// panics, which would otherwise end the span or the batch goroutine
+type guardedExporter struct {
+	logger   logrus.FieldLogger
+	exporter sdktrace.SpanExporter
+}
+
+// ExportSpans exports the spans, the exporter may reorder or trim them
+func (e guardedExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) (err error) {
+	defer e.recover(&err)
+	return e.exporter.ExportSpans(ctx, append([]sdktrace.ReadOnlySpan(nil), spans...))
+}
+
+// Shutdown stops the exporter
+func (e guardedExporter) Shutdown(ctx context.Context) (err error) {
+	defer e.recover(&err)
+	return e.exporter.Shutdown(ctx)
+}
+
+func (e guardedExporter) recover(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("exporter panicked: %v", r)
+	}
+	if *err != nil {
+		e.logger.WithError(*err).Errorf("exporter %T failed", e.exporter)
+	}
+}
+
+// exportersError the errors of the exporters which failed
+type exportersError []error
+
+func (e exportersError) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("%d exporters failed: %s", len(e), strings.Join(messages, "; "))
+}
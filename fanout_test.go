@@ -0,0 +1,128 @@
+package lumigotracer
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// funcExporter an exporter calling export for each batch
+type funcExporter struct {
+	export func([]sdktrace.ReadOnlySpan) error
+}
+
+func (e funcExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	return e.export(spans)
+}
+
+func (e funcExporter) Shutdown(ctx context.Context) error { return nil }
+
+func TestFanOutProcessor(t *testing.T) {
+	logger.Out = ioutil.Discard
+	first := tracetest.NewInMemoryExporter()
+	second := tracetest.NewInMemoryExporter()
+	failing := funcExporter{export: func([]sdktrace.ReadOnlySpan) error {
+		return errors.New("collector unavailable")
+	}}
+	panicking := funcExporter{export: func([]sdktrace.ReadOnlySpan) error {
+		panic("nil map")
+	}}
+	// the blocking exporter never returns until the end of the test
+	unblock := make(chan struct{})
+	defer close(unblock)
+	blocking := funcExporter{export: func([]sdktrace.ReadOnlySpan) error {
+		<-unblock
+		return nil
+	}}
+
+	processor := newFanOutProcessor(logger, batchedExporter{first}, batchedExporter{failing}, batchedExporter{blocking}, batchedExporter{panicking}, batchedExporter{second})
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(processor)).Tracer("test")
+	for i, name := range []string{"first", "second"} {
+		_, span := tracer.Start(context.Background(), name)
+		span.End()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		err := processor.ForceFlush(ctx)
+		cancel()
+
+		var failed exportersError
+		assert.True(t, errors.As(err, &failed))
+		assert.Len(t, failed, 3)
+		assert.Contains(t, err.Error(), "collector unavailable")
+		assert.Contains(t, err.Error(), "exporter panicked: nil map")
+		assert.Contains(t, err.Error(), context.DeadlineExceeded.Error())
+
+		// the others still get the later batches of the blocked exporter
+		assert.Len(t, first.GetSpans(), i+1)
+		assert.Len(t, second.GetSpans(), i+1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.Error(t, processor.Shutdown(ctx))
+	assert.Empty(t, first.GetSpans())
+}
+
+func TestFanOutProcessorSynchronousExporters(t *testing.T) {
+	logger.Out = ioutil.Discard
+	synchronous := tracetest.NewInMemoryExporter()
+	batched := tracetest.NewInMemoryExporter()
+	panicking := funcExporter{export: func([]sdktrace.ReadOnlySpan) error {
+		panic("nil map")
+	}}
+
+	processor := newFanOutProcessor(logger, synchronous, panicking, batchedExporter{batched})
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(processor)).Tracer("test")
+	_, span := tracer.Start(context.Background(), "span")
+	span.End()
+
+	// the synchronous exporters get the span as it ends, the batched
+	// ones once they are flushed
+	assert.Len(t, synchronous.GetSpans(), 1)
+	assert.Empty(t, batched.GetSpans())
+	assert.NoError(t, processor.ForceFlush(context.Background()))
+	assert.Len(t, batched.GetSpans(), 1)
+}
+
+func TestCreateExporters(t *testing.T) {
+	logger.Out = ioutil.Discard
+	custom := tracetest.NewInMemoryExporter()
+
+	exporters, err := createExporters(Config{
+		SpansExporters: []string{StdoutSpansExporter},
+		Exporters:      []sdktrace.SpanExporter{custom},
+	}, context.Background(), logger)
+	assert.NoError(t, err)
+	assert.Len(t, exporters, 2)
+
+	// the exporters of the config outlive the processor of an invocation
+	shared := exporters[1]
+	assert.Equal(t, batchedExporter{sharedExporter{custom}}, shared)
+	custom.ExportSpans(context.Background(), tracetest.SpanStubs{{Name: "span"}}.Snapshots()) // nolint
+	assert.NoError(t, shared.Shutdown(context.Background()))
+	assert.Len(t, custom.GetSpans(), 1)
+}
+
+func TestCreateRegisteredExporter(t *testing.T) {
+	logger.Out = ioutil.Discard
+	registered := tracetest.NewInMemoryExporter()
+	RegisterSpansExporter("memory", func(ctx context.Context) (sdktrace.SpanExporter, error) {
+		return registered, nil
+	})
+	defer func() {
+		spansExportersMu.Lock()
+		defer spansExportersMu.Unlock()
+		delete(spansExporterFuncs, "memory")
+	}()
+
+	exporters, err := createExporters(Config{SpansExporters: []string{"memory", "kafka"}}, context.Background(), logger)
+	assert.NoError(t, err)
+	// the registered exporters outlive the processor of an invocation
+	assert.Equal(t, []sdktrace.SpanExporter{batchedExporter{sharedExporter{registered}}}, exporters)
+}
@@ -0,0 +1,95 @@
+package lumigotracer
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// maskedValue replaces the value of sensitive headers
+const maskedValue = "****"
+
+// defaultSecretMaskingRegex header names matching any of these
+// are masked unless LUMIGO_SECRET_MASKING_REGEX is set
+var defaultSecretMaskingRegex = []string{
+	".*pass.*",
+	".*key.*",
+	".*secret.*",
+	".*credential.*",
+	"SessionToken",
+	"x-amz-security-token",
+	"Signature",
+	"Authorization",
+	"Cookie",
+	"Set-Cookie",
+}
+
+// defaultSecretMaskingRegexps the compiled defaultSecretMaskingRegex
+var defaultSecretMaskingRegexps = compileRegexList(defaultSecretMaskingRegex)
+
+// headerFilter decides which HTTP headers are captured
+// and which of them are masked
+type headerFilter struct {
+	allowlist map[string]bool
+	denylist  map[string]bool
+	masking   []*regexp.Regexp
+}
+
+func newHeaderFilter(allowlist, denylist []string, masking []*regexp.Regexp) headerFilter {
+	return headerFilter{
+		allowlist: canonicalHeaderSet(allowlist),
+		denylist:  canonicalHeaderSet(denylist),
+		masking:   masking,
+	}
+}
+
+// format returns the captured headers as json, joining multiple
+// values of the same header in their canonical comma separated form,
+// except for Set-Cookie whose values may contain commas and are kept
+// as a list
+func (f headerFilter) format(header http.Header) string {
+	headers := make(map[string]interface{}, len(header))
+	for k, values := range header {
+		name := http.CanonicalHeaderKey(k)
+		if len(f.allowlist) > 0 && !f.allowlist[name] {
+			continue
+		}
+		if f.denylist[name] {
+			continue
+		}
+		if f.isSecret(name) {
+			headers[name] = maskedValue
+			continue
+		}
+		if name == "Set-Cookie" {
+			headers[name] = values
+			continue
+		}
+		headers[name] = strings.Join(values, ", ")
+	}
+	headersJson, err := json.Marshal(headers)
+	if err != nil {
+		logger.WithError(err).Error("failed to fetch headers")
+	}
+	return string(headersJson)
+}
+
+func (f headerFilter) isSecret(name string) bool {
+	for _, re := range f.masking {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func canonicalHeaderSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			set[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,104 @@
+package lumigotracer
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderFilterFormat(t *testing.T) {
+	header := http.Header{
+		"Set-Cookie":    []string{"a=1; Expires=Wed, 21 Oct 2026 07:28:00 GMT", "b=2"},
+		"Cookie":        []string{"session=123"},
+		"Via":           []string{"1.1 proxy1", "1.1 proxy2"},
+		"Content-Type":  []string{"application/json"},
+		"Authorization": []string{"Bearer secret"},
+		"X-Api-Key":     []string{"key"},
+		"X-Request-Id":  []string{"123"},
+	}
+
+	testcases := []struct {
+		testname  string
+		allowlist []string
+		denylist  []string
+		masking   []*regexp.Regexp
+		expected  map[string]interface{}
+	}{
+		{
+			testname: "multi value headers are joined",
+			expected: map[string]interface{}{
+				"Set-Cookie":    maskedValue,
+				"Cookie":        maskedValue,
+				"Via":           "1.1 proxy1, 1.1 proxy2",
+				"Content-Type":  "application/json",
+				"Authorization": maskedValue,
+				"X-Api-Key":     maskedValue,
+				"X-Request-Id":  "123",
+			},
+		},
+		{
+			testname: "set-cookie values are never joined",
+			masking:  compileRegexList([]string{"Authorization"}),
+			expected: map[string]interface{}{
+				"Set-Cookie":    []interface{}{"a=1; Expires=Wed, 21 Oct 2026 07:28:00 GMT", "b=2"},
+				"Cookie":        "session=123",
+				"Via":           "1.1 proxy1, 1.1 proxy2",
+				"Content-Type":  "application/json",
+				"Authorization": maskedValue,
+				"X-Api-Key":     "key",
+				"X-Request-Id":  "123",
+			},
+		},
+		{
+			testname:  "allowlist",
+			allowlist: []string{"via", "authorization"},
+			expected: map[string]interface{}{
+				"Via":           "1.1 proxy1, 1.1 proxy2",
+				"Authorization": maskedValue,
+			},
+		},
+		{
+			testname: "denylist",
+			denylist: []string{"set-cookie", "cookie", "x-api-key", "authorization"},
+			expected: map[string]interface{}{
+				"Via":          "1.1 proxy1, 1.1 proxy2",
+				"Content-Type": "application/json",
+				"X-Request-Id": "123",
+			},
+		},
+		{
+			testname:  "denylist wins over allowlist",
+			allowlist: []string{"Via", "X-Request-Id"},
+			denylist:  []string{"Via"},
+			expected: map[string]interface{}{
+				"X-Request-Id": "123",
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testname, func(t *testing.T) {
+			masking := tc.masking
+			if masking == nil {
+				masking = defaultSecretMaskingRegexps
+			}
+			filter := newHeaderFilter(tc.allowlist, tc.denylist, masking)
+			var headers map[string]interface{}
+			assert.NoError(t, json.Unmarshal([]byte(filter.format(header)), &headers))
+			assert.Equal(t, tc.expected, headers)
+		})
+	}
+}
+
+func TestCompileRegexList(t *testing.T) {
+	compiled := compileRegexList([]string{"x-custom-.*", "[invalid"})
+	assert.Len(t, compiled, 1)
+
+	filter := newHeaderFilter(nil, nil, compiled)
+	assert.True(t, filter.isSecret("X-Custom-Secret"))
+	assert.False(t, filter.isSecret("Authorization"))
+	assert.False(t, filter.isSecret("X-Not-Custom"))
+}
@@ -0,0 +1,41 @@
+package lumigotracer
+
+import (
+	"net/http"
+	"os"
+	"regexp"
+)
+
+// defaultIgnoredDomains outgoing HTTP calls which are
+// never traced, Lumigo's own endpoints
+var defaultIgnoredDomains = []string{
+	`.*\.lumigo\.io`,
+	`.*lumigo-tracer-edge\.golumigo\.com`,
+}
+
+// defaultIgnoredDomainsRegexps the compiled defaultIgnoredDomains
+var defaultIgnoredDomainsRegexps = compileRegexList(defaultIgnoredDomains)
+
+// requestIgnorer decides which outgoing HTTP calls are passed
+// through without creating an HttpSpan
+type requestIgnorer struct {
+	domains []*regexp.Regexp
+	paths   []*regexp.Regexp
+}
+
+func (ri requestIgnorer) isIgnored(req *http.Request) bool {
+	// the Lambda runtime API is called by the lambda library itself
+	if runtimeAPI := os.Getenv("AWS_LAMBDA_RUNTIME_API"); runtimeAPI != "" && req.URL.Host == runtimeAPI {
+		return true
+	}
+	return matchAny(ri.domains, req.URL.Hostname()) || matchAny(ri.paths, req.URL.Path)
+}
+
+func matchAny(patterns []*regexp.Regexp, value string) bool {
+	for _, re := range patterns {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package lumigotracer
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+func TestRequestIgnorer(t *testing.T) {
+	os.Setenv("AWS_LAMBDA_RUNTIME_API", "127.0.0.1:9001")
+	defer os.Unsetenv("AWS_LAMBDA_RUNTIME_API")
+
+	ignorer := requestIgnorer{
+		domains: append(compileRegexList([]string{`metadata\.internal`}), defaultIgnoredDomainsRegexps...),
+		paths:   compileRegexList([]string{"/health.*"}),
+	}
+
+	testcases := []struct {
+		testname string
+		url      string
+		expected bool
+	}{
+		{
+			testname: "lambda runtime api",
+			url:      "http://127.0.0.1:9001/2018-06-01/runtime/invocation/next",
+			expected: true,
+		},
+		{
+			testname: "same host other port",
+			url:      "http://127.0.0.1:8080/2018-06-01/runtime/invocation/next",
+			expected: false,
+		},
+		{
+			testname: "lumigo edge",
+			url:      "https://us-east-1.lumigo-tracer-edge.golumigo.com/api/spans",
+			expected: true,
+		},
+		{
+			testname: "custom domain",
+			url:      "http://METADATA.internal:80/latest",
+			expected: true,
+		},
+		{
+			testname: "custom domain is matched as a whole",
+			url:      "http://metadata.internal.example.com/latest",
+			expected: false,
+		},
+		{
+			testname: "custom path",
+			url:      "https://example.com/healthcheck",
+			expected: true,
+		},
+		{
+			testname: "traced call",
+			url:      "https://dynamodb.us-east-1.amazonaws.com/",
+			expected: false,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testname, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tc.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.expected, ignorer.isIgnored(req))
+		})
+	}
+}
+
+func TestTransportIgnoredPath(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := w.Write([]byte("ok")); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer ts.Close()
+
+	sr := tracetest.NewSpanRecorder()
+	tr := NewTransport(http.DefaultTransport, WithIgnoredPaths("/health"))
+	tr.provider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	c := http.Client{Transport: tr}
+
+	for _, path := range []string{"/health", "/orders"} {
+		res, err := c.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "ok", string(body))
+		assert.NoError(t, res.Body.Close())
+	}
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 1)
+	assert.Contains(t, spans[0].Attributes(), semconv.HTTPTargetKey.String("/orders"))
+}
@@ -0,0 +1,53 @@
+package lumigotracer
+
+import (
+	"net/http"
+	"sync"
+)
+
+var (
+	// defaultTransportMu guards the swaps of http.DefaultTransport
+	defaultTransportMu sync.Mutex
+	// installedTransport the Transport InstrumentDefaultTransport
+	// installed as http.DefaultTransport
+	installedTransport *Transport
+)
+
+// InstrumentDefaultTransport replaces http.DefaultTransport with a
+// Transport wrapping it, so that every client relying on the default
+// transport is traced. Calling it again while instrumented, or when
+// http.DefaultTransport already is a Transport, is a no-op.
+// It returns a function restoring the original default transport.
+//
+// http.DefaultTransport is then no longer an *http.Transport, so code
+// asserting it is, like http.DefaultTransport.(*http.Transport).Clone(),
+// panics. Do not instrument it if any dependency does so, wrap the
+// clients with NewTransport instead.
+func InstrumentDefaultTransport(opts ...TransportOption) (restore func()) {
+	defaultTransportMu.Lock()
+	defer defaultTransportMu.Unlock()
+
+	if _, ok := http.DefaultTransport.(*Transport); ok {
+		logger.Info("http.DefaultTransport is already instrumented")
+		return UninstrumentDefaultTransport
+	}
+	installedTransport = NewTransport(http.DefaultTransport, opts...)
+	http.DefaultTransport = installedTransport
+	return UninstrumentDefaultTransport
+}
+
+// UninstrumentDefaultTransport restores the http.DefaultTransport
+// replaced by InstrumentDefaultTransport, a Transport installed
+// by other means is left in place
+func UninstrumentDefaultTransport() {
+	defaultTransportMu.Lock()
+	defer defaultTransportMu.Unlock()
+
+	if installedTransport == nil {
+		return
+	}
+	if http.DefaultTransport == installedTransport {
+		http.DefaultTransport = installedTransport.rt
+	}
+	installedTransport = nil
+}
@@ -0,0 +1,103 @@
+package lumigotracer
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestInstrumentDefaultTransport(t *testing.T) {
+	original := http.DefaultTransport
+
+	restore := InstrumentDefaultTransport()
+	instrumented, ok := http.DefaultTransport.(*Transport)
+	assert.True(t, ok)
+	assert.Equal(t, original, instrumented.rt)
+
+	// no double wrapping
+	InstrumentDefaultTransport()
+	assert.Equal(t, instrumented, http.DefaultTransport)
+
+	restore()
+	assert.Equal(t, original, http.DefaultTransport)
+
+	// restoring twice keeps the original
+	UninstrumentDefaultTransport()
+	assert.Equal(t, original, http.DefaultTransport)
+}
+
+func TestInstrumentDefaultTransportTracesDefaultClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := w.Write([]byte("ok")); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer ts.Close()
+
+	sr := tracetest.NewSpanRecorder()
+	previousProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	defer otel.SetTracerProvider(previousProvider)
+
+	defer InstrumentDefaultTransport()()
+	res, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(res.Body); err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, res.Body.Close())
+	assert.Len(t, sr.Ended(), 1)
+}
+
+func TestInstrumentDefaultTransportKeepsUserTransport(t *testing.T) {
+	original := http.DefaultTransport
+	defer func() { http.DefaultTransport = original }()
+
+	userTransport := NewTransport(original)
+	http.DefaultTransport = userTransport
+
+	InstrumentDefaultTransport()
+	assert.Equal(t, userTransport, http.DefaultTransport)
+
+	// only the transport installed by InstrumentDefaultTransport is removed
+	UninstrumentDefaultTransport()
+	assert.Equal(t, userTransport, http.DefaultTransport)
+}
+
+func TestNewTransportOnInstrumentedDefaultTransport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := w.Write([]byte("ok")); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer ts.Close()
+
+	sr := tracetest.NewSpanRecorder()
+	previousProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	defer otel.SetTracerProvider(previousProvider)
+
+	original := http.DefaultTransport
+	defer InstrumentDefaultTransport()()
+	transport := NewTransport(http.DefaultTransport)
+	assert.Equal(t, original, transport.rt)
+
+	c := &http.Client{Transport: transport}
+	res, err := c.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(res.Body); err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, res.Body.Close())
+	assert.Len(t, sr.Ended(), 1)
+}
@@ -0,0 +1,225 @@
+package awsoperation
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+
+	lumigoctx "github.com/lumigo-io/go-tracer-beta/internal/context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// maxMessageAttributes the most message attributes SQS and SNS accept
+const maxMessageAttributes = 10
+
+// maxClientContextSize the largest base64 ClientContext Lambda accepts
+const maxClientContextSize = 3583
+
+// EventBridgeTraceContextKey the EventBridge detail field holding
+// the trace context
+const EventBridgeTraceContextKey = "_traceContext"
+
+// TransactionIDKey the ClientContext custom field holding the
+// transaction ID of the caller
+const TransactionIDKey = "lumigo_transaction_id"
+
+// InjectMessagingContext returns a copy of params, the typed input of an
+// AWS SDK operation of either SDK, whose messages and invocations carry
+// the trace context of ctx. The input of the caller is left unchanged,
+// params itself is returned if there is nothing to inject
+func InjectMessagingContext(ctx context.Context, service, operation string, params interface{}) interface{} {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return params
+	}
+	v := reflect.ValueOf(params)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return params
+	}
+	copied := reflect.New(v.Elem().Type())
+	copied.Elem().Set(v.Elem())
+	input := copied.Elem()
+
+	switch {
+	case service == "sqs" && operation == "SendMessage",
+		service == "sns" && operation == "Publish":
+		injectMessageAttributes(input, carrier)
+	case service == "sqs" && operation == "SendMessageBatch":
+		forEachEntry(input.FieldByName("Entries"), func(entry reflect.Value) {
+			injectMessageAttributes(entry, carrier)
+		})
+	case service == "sns" && operation == "PublishBatch":
+		forEachEntry(input.FieldByName("PublishBatchRequestEntries"), func(entry reflect.Value) {
+			injectMessageAttributes(entry, carrier)
+		})
+	case service == "lambda" && operation == "Invoke":
+		injectClientContext(ctx, input, carrier)
+	case service == "events" && operation == "PutEvents":
+		forEachEntry(input.FieldByName("Entries"), func(entry reflect.Value) {
+			injectEventDetail(entry, carrier)
+		})
+	default:
+		return params
+	}
+	return copied.Interface()
+}
+
+// forEachEntry replaces the entries of a batch with copies and calls fn
+// with the struct of each copy, the SDK v1 entries are pointers and the
+// v2 ones are values
+func forEachEntry(entries reflect.Value, fn func(reflect.Value)) {
+	if entries.Kind() != reflect.Slice || entries.IsNil() || !entries.CanSet() {
+		return
+	}
+	copied := reflect.MakeSlice(entries.Type(), entries.Len(), entries.Len())
+	reflect.Copy(copied, entries)
+	for i := 0; i < copied.Len(); i++ {
+		entry := copied.Index(i)
+		if entry.Kind() == reflect.Ptr {
+			if entry.IsNil() || entry.Elem().Kind() != reflect.Struct {
+				continue
+			}
+			copiedEntry := reflect.New(entry.Type().Elem())
+			copiedEntry.Elem().Set(entry.Elem())
+			entry.Set(copiedEntry)
+			entry = copiedEntry.Elem()
+		}
+		if entry.Kind() == reflect.Struct {
+			fn(entry)
+		}
+	}
+	entries.Set(copied)
+}
+
+// injectMessageAttributes adds the carrier as string message attributes,
+// skipping the message if they would exceed the attributes limit
+func injectMessageAttributes(message reflect.Value, carrier propagation.MapCarrier) {
+	attributes := message.FieldByName("MessageAttributes")
+	if attributes.Kind() != reflect.Map || attributes.Type().Key().Kind() != reflect.String || !attributes.CanSet() {
+		return
+	}
+	added := 0
+	for key := range carrier {
+		if !attributes.MapIndex(reflect.ValueOf(key)).IsValid() {
+			added++
+		}
+	}
+	if attributes.Len()+added > maxMessageAttributes {
+		logger.Warn("too many message attributes to add the trace context")
+		return
+	}
+	// the map of the caller is copied rather than written to
+	copied := reflect.MakeMapWithSize(attributes.Type(), attributes.Len()+added)
+	iter := attributes.MapRange()
+	for iter.Next() {
+		copied.SetMapIndex(iter.Key(), iter.Value())
+	}
+	for key, value := range carrier {
+		attribute, ok := newStringAttribute(attributes.Type().Elem(), value)
+		if !ok {
+			return
+		}
+		copied.SetMapIndex(reflect.ValueOf(key), attribute)
+	}
+	attributes.Set(copied)
+}
+
+// newStringAttribute builds a message attribute value of either SDK,
+// a struct or a pointer to a struct with DataType and StringValue
+func newStringAttribute(attributeType reflect.Type, value string) (reflect.Value, bool) {
+	structType := attributeType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	attribute := reflect.New(structType)
+	dataType := attribute.Elem().FieldByName("DataType")
+	stringValue := attribute.Elem().FieldByName("StringValue")
+	stringPtr := reflect.TypeOf((*string)(nil))
+	if dataType.Type() != stringPtr || stringValue.Type() != stringPtr {
+		return reflect.Value{}, false
+	}
+	dataTypeString := "String"
+	dataType.Set(reflect.ValueOf(&dataTypeString))
+	stringValue.Set(reflect.ValueOf(&value))
+	if attributeType.Kind() == reflect.Ptr {
+		return attribute, true
+	}
+	return attribute.Elem(), true
+}
+
+// injectEventDetail adds the carrier to the json object detail of an
+// EventBridge entry
+func injectEventDetail(entry reflect.Value, carrier propagation.MapCarrier) {
+	detail := entry.FieldByName("Detail")
+	if detail.Kind() != reflect.Ptr || detail.IsNil() || detail.Elem().Kind() != reflect.String {
+		return
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(detail.Elem().String()), &fields); err != nil || fields == nil {
+		return
+	}
+	traceContext, err := json.Marshal(carrier)
+	if err != nil {
+		return
+	}
+	fields[EventBridgeTraceContextKey] = traceContext
+	injected, err := json.Marshal(fields)
+	if err != nil {
+		return
+	}
+	injectedDetail := string(injected)
+	detail.Set(reflect.ValueOf(&injectedDetail))
+}
+
+// injectClientContext adds the carrier and the transaction ID of the
+// caller to the custom fields of the ClientContext of an Invoke input,
+// keeping the other fields of an existing ClientContext
+func injectClientContext(ctx context.Context, input reflect.Value, carrier propagation.MapCarrier) {
+	field := input.FieldByName("ClientContext")
+	if field.Kind() != reflect.Ptr || field.Type().Elem().Kind() != reflect.String {
+		return
+	}
+	clientContext := make(map[string]json.RawMessage)
+	if !field.IsNil() && field.Elem().String() != "" {
+		decoded, err := base64.StdEncoding.DecodeString(field.Elem().String())
+		if err != nil {
+			return
+		}
+		if err := json.Unmarshal(decoded, &clientContext); err != nil {
+			return
+		}
+	}
+	custom := make(map[string]string)
+	if raw, ok := clientContext["custom"]; ok {
+		if err := json.Unmarshal(raw, &custom); err != nil {
+			return
+		}
+	}
+	for key, value := range carrier {
+		custom[key] = value
+	}
+	if lumigoCtx, ok := lumigoctx.FromContext(ctx); ok && lumigoCtx.TransactionID != "" {
+		custom[TransactionIDKey] = lumigoCtx.TransactionID
+	}
+	raw, err := json.Marshal(custom)
+	if err != nil {
+		return
+	}
+	clientContext["custom"] = raw
+	encoded, err := json.Marshal(clientContext)
+	if err != nil {
+		return
+	}
+	injected := base64.StdEncoding.EncodeToString(encoded)
+	if len(injected) > maxClientContextSize {
+		logger.Warn("client context too large to add the trace context")
+		return
+	}
+	field.Set(reflect.ValueOf(&injected))
+}
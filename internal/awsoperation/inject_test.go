@@ -0,0 +1,127 @@
+package awsoperation
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	lumigoctx "github.com/lumigo-io/go-tracer-beta/internal/context"
+	"github.com/lumigo-io/go-tracer-beta/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// v2MessageAttributeValue mimics the aws-sdk-go-v2 message attributes,
+// which are values instead of pointers
+type v2MessageAttributeValue struct {
+	DataType    *string
+	StringValue *string
+	BinaryValue []byte
+}
+
+type v2SendMessageBatchInput struct {
+	Entries []struct {
+		Id                *string
+		MessageAttributes map[string]v2MessageAttributeValue
+	}
+	QueueUrl *string
+}
+
+func TestInjectMessagingContext(t *testing.T) {
+	ctx := testutil.WithTraceContextPropagator(t)
+
+	sendMessage := &sqs.SendMessageInput{QueueUrl: aws.String("queue")}
+	injectedSendMessage := InjectMessagingContext(ctx, "sqs", "SendMessage", sendMessage).(*sqs.SendMessageInput)
+	assert.Equal(t, testutil.Traceparent, aws.StringValue(injectedSendMessage.MessageAttributes["traceparent"].StringValue))
+	assert.Equal(t, "String", aws.StringValue(injectedSendMessage.MessageAttributes["traceparent"].DataType))
+	assert.Equal(t, "queue", aws.StringValue(injectedSendMessage.QueueUrl))
+	assert.Nil(t, sendMessage.MessageAttributes)
+
+	publish := &sns.PublishInput{MessageAttributes: map[string]*sns.MessageAttributeValue{
+		"kind": {DataType: aws.String("String"), StringValue: aws.String("order")},
+	}}
+	injectedPublish := InjectMessagingContext(ctx, "sns", "Publish", publish).(*sns.PublishInput)
+	assert.Len(t, injectedPublish.MessageAttributes, 2)
+	assert.Equal(t, testutil.Traceparent, aws.StringValue(injectedPublish.MessageAttributes["traceparent"].StringValue))
+	assert.Len(t, publish.MessageAttributes, 1)
+
+	batch := &v2SendMessageBatchInput{}
+	batch.Entries = append(batch.Entries, struct {
+		Id                *string
+		MessageAttributes map[string]v2MessageAttributeValue
+	}{Id: aws.String("1")})
+	injectedBatch := InjectMessagingContext(ctx, "sqs", "SendMessageBatch", batch).(*v2SendMessageBatchInput)
+	assert.Equal(t, testutil.Traceparent, aws.StringValue(injectedBatch.Entries[0].MessageAttributes["traceparent"].StringValue))
+	assert.Nil(t, batch.Entries[0].MessageAttributes)
+
+	putEvents := &eventbridge.PutEventsInput{Entries: []*eventbridge.PutEventsRequestEntry{
+		{Detail: aws.String(`{"id":1}`)},
+		{Detail: aws.String(`[1]`)},
+	}}
+	injectedPutEvents := InjectMessagingContext(ctx, "events", "PutEvents", putEvents).(*eventbridge.PutEventsInput)
+	assert.JSONEq(t, `{"id":1,"_traceContext":{"traceparent":"`+testutil.Traceparent+`"}}`, aws.StringValue(injectedPutEvents.Entries[0].Detail))
+	assert.Equal(t, `[1]`, aws.StringValue(injectedPutEvents.Entries[1].Detail))
+	assert.Equal(t, `{"id":1}`, aws.StringValue(putEvents.Entries[0].Detail))
+
+	// the inputs of the other operations are not copied
+	putItem := &putItemInput{TableName: aws.String("orders")}
+	assert.Same(t, putItem, InjectMessagingContext(ctx, "dynamodb", "PutItem", putItem))
+}
+
+func TestInjectMessagingContextLimit(t *testing.T) {
+	ctx := testutil.WithTraceContextPropagator(t)
+
+	attributes := make(map[string]*sqs.MessageAttributeValue)
+	for i := 0; i < maxMessageAttributes; i++ {
+		attributes[fmt.Sprintf("attr%d", i)] = &sqs.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String("v")}
+	}
+	sendMessage := InjectMessagingContext(ctx, "sqs", "SendMessage", &sqs.SendMessageInput{MessageAttributes: attributes}).(*sqs.SendMessageInput)
+	assert.Len(t, sendMessage.MessageAttributes, maxMessageAttributes)
+	assert.NotContains(t, sendMessage.MessageAttributes, "traceparent")
+
+	// without a span there is nothing to inject
+	sendMessage = &sqs.SendMessageInput{}
+	assert.Same(t, sendMessage, InjectMessagingContext(context.Background(), "sqs", "SendMessage", sendMessage))
+	assert.Nil(t, sendMessage.MessageAttributes)
+}
+
+func decodeClientContext(t *testing.T, clientContext *string) map[string]json.RawMessage {
+	decoded, err := base64.StdEncoding.DecodeString(aws.StringValue(clientContext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(decoded, &fields); err != nil {
+		t.Fatal(err)
+	}
+	return fields
+}
+
+func TestInjectClientContext(t *testing.T) {
+	ctx := lumigoctx.NewContext(testutil.WithTraceContextPropagator(t), &lumigoctx.LumigoContext{TransactionID: "transaction"})
+
+	invoke := &lambda.InvokeInput{FunctionName: aws.String("callee")}
+	injected := InjectMessagingContext(ctx, "lambda", "Invoke", invoke).(*lambda.InvokeInput)
+	assert.Nil(t, invoke.ClientContext)
+	fields := decodeClientContext(t, injected.ClientContext)
+	assert.JSONEq(t, `{"traceparent":"`+testutil.Traceparent+`","lumigo_transaction_id":"transaction"}`, string(fields["custom"]))
+
+	existing := base64.StdEncoding.EncodeToString([]byte(`{"env":{"locale":"en"},"custom":{"user":"1"}}`))
+	invoke = &lambda.InvokeInput{ClientContext: aws.String(existing)}
+	injected = InjectMessagingContext(ctx, "lambda", "Invoke", invoke).(*lambda.InvokeInput)
+	assert.Equal(t, existing, aws.StringValue(invoke.ClientContext))
+	fields = decodeClientContext(t, injected.ClientContext)
+	assert.JSONEq(t, `{"locale":"en"}`, string(fields["env"]))
+	assert.JSONEq(t, `{"user":"1","traceparent":"`+testutil.Traceparent+`","lumigo_transaction_id":"transaction"}`, string(fields["custom"]))
+
+	large := base64.StdEncoding.EncodeToString([]byte(`{"custom":{"user":"` + strings.Repeat("a", 2600) + `"}}`))
+	injected = InjectMessagingContext(ctx, "lambda", "Invoke", &lambda.InvokeInput{ClientContext: aws.String(large)}).(*lambda.InvokeInput)
+	assert.Equal(t, large, aws.StringValue(injected.ClientContext))
+}
@@ -0,0 +1,232 @@
+package awsoperation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxParamLength the longest parameter value kept in the params summary
+const maxParamLength = 64
+
+// maskedValue replaces the value of secret params, alike secret headers
+const maskedValue = "****"
+
+var (
+	logger   logrus.FieldLogger = logrus.StandardLogger()
+	isSecret                    = func(string) bool { return false }
+)
+
+// Configure sets the logger and the check of the secret param names
+// of the operations, the tracer sets them on init
+func Configure(l logrus.FieldLogger, secret func(name string) bool) {
+	logger = l
+	isSecret = secret
+}
+
+// awsServiceNames the service names of the endpoints whose service ID
+// differs, so that operation and HTTP spans name services alike
+var awsServiceNames = map[string]string{
+	"eventbridge": "events",
+	"sfn":         "states",
+}
+
+// awsResourceFields the input fields naming the resource of an
+// operation, the first one set is recorded
+var awsResourceFields = []string{
+	"TableName",
+	"Bucket",
+	"QueueUrl",
+	"TopicArn",
+	"TargetArn",
+	"FunctionName",
+	"StreamName",
+	"StreamARN",
+	"EventBusName",
+	"StateMachineArn",
+}
+
+// StartSpan starts the span of an AWS SDK operation, alike for both
+// SDK versions, params is its typed input
+func StartSpan(ctx context.Context, service, operation, region string, params interface{}) (context.Context, trace.Span) {
+	ctx, span := otel.GetTracerProvider().Tracer("lumigo").Start(ctx, fmt.Sprintf("%s.%s", service, operation),
+		trace.WithSpanKind(trace.SpanKindClient))
+	attrs := startAttributes(service, operation, region)
+	span.SetAttributes(append(attrs, paramsAttributes(params)...)...)
+	return ctx, span
+}
+
+// startAttributes the span attributes of an AWS SDK operation known
+// when it starts
+func startAttributes(service, operation, region string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.RPCSystemKey.String("aws-api"),
+		semconv.RPCServiceKey.String(service),
+		semconv.RPCMethodKey.String(operation),
+	}
+	if region != "" {
+		attrs = append(attrs, attribute.String("aws.region", region))
+	}
+	return attrs
+}
+
+// paramsAttributes the span attributes describing the typed
+// input of an AWS SDK operation
+func paramsAttributes(params interface{}) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if resourceName := inputResourceName(params); resourceName != "" {
+		attrs = append(attrs, attribute.String("aws.resource_name", resourceName))
+	}
+	if summary := paramsSummary(params); summary != "" {
+		attrs = append(attrs, attribute.String("aws.params", summary))
+	}
+	return attrs
+}
+
+// AddAttemptFailedEvent records a failed attempt of an operation,
+// attempts are numbered from 1
+func AddAttemptFailedEvent(span trace.Span, attempt int, err error) {
+	span.AddEvent("aws.attempt_failed", trace.WithAttributes(
+		attribute.Int("aws.attempt", attempt),
+		attribute.String("error_message", err.Error()),
+	))
+}
+
+// EndSpan records the result of an AWS SDK operation and ends
+// its span, zero values are unknown
+func EndSpan(span trace.Span, requestID string, statusCode, attempts int, err error) {
+	var attrs []attribute.KeyValue
+	if requestID != "" {
+		attrs = append(attrs, attribute.String("aws.request_id", requestID))
+	}
+	if statusCode != 0 {
+		attrs = append(attrs, semconv.HTTPStatusCodeKey.Int(statusCode))
+	}
+	if attempts != 0 {
+		attrs = append(attrs, attribute.Int("aws.attempts", attempts))
+	}
+	span.SetAttributes(attrs...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// ServiceName the lowercase service name of a service ID
+// e.g. DynamoDB or Service Catalog
+func ServiceName(serviceID string) string {
+	name := strings.ToLower(strings.ReplaceAll(serviceID, " ", ""))
+	if mapped, ok := awsServiceNames[name]; ok {
+		return mapped
+	}
+	return name
+}
+
+// inputStruct dereferences the typed input of an operation, which
+// is a pointer to a struct of pointer fields
+func inputStruct(params interface{}) (reflect.Value, bool) {
+	v := reflect.ValueOf(params)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return v, false
+		}
+		v = v.Elem()
+	}
+	return v, v.Kind() == reflect.Struct
+}
+
+// inputResourceName finds the resource of an operation in its input
+func inputResourceName(params interface{}) string {
+	v, ok := inputStruct(params)
+	if !ok {
+		return ""
+	}
+	for _, name := range awsResourceFields {
+		field := v.FieldByName(name)
+		if field.Kind() == reflect.Ptr && !field.IsNil() {
+			field = field.Elem()
+		}
+		if field.Kind() == reflect.String && field.String() != "" {
+			return field.String()
+		}
+	}
+	return ""
+}
+
+// paramsSummary renders the set fields of an operation input as
+// json, keeping short scalar values and only the size of the others.
+// Secret fields are masked like secret headers.
+func paramsSummary(params interface{}) string {
+	v, ok := inputStruct(params)
+	if !ok {
+		return ""
+	}
+	summary := make(map[string]interface{})
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		field := v.Field(i)
+		if !v.Type().Field(i).IsExported() || isZeroParam(field) {
+			continue
+		}
+		if field.Kind() == reflect.Ptr {
+			field = field.Elem()
+		}
+		switch {
+		case isSecret(name):
+			summary[name] = maskedValue
+		case field.Kind() == reflect.String:
+			summary[name] = truncate(field.String(), maxParamLength)
+		case field.Kind() == reflect.Bool:
+			summary[name] = field.Bool()
+		case field.Kind() >= reflect.Int && field.Kind() <= reflect.Int64:
+			summary[name] = field.Int()
+		case field.Kind() >= reflect.Uint && field.Kind() <= reflect.Uint64:
+			summary[name] = field.Uint()
+		case field.Kind() == reflect.Float32 || field.Kind() == reflect.Float64:
+			summary[name] = field.Float()
+		case field.Kind() == reflect.Map || field.Kind() == reflect.Slice || field.Kind() == reflect.Array:
+			summary[name] = fmt.Sprintf("<%d items>", field.Len())
+		default:
+			summary[name] = fmt.Sprintf("<%s>", field.Type())
+		}
+	}
+	if len(summary) == 0 {
+		return ""
+	}
+	var summaryJson bytes.Buffer
+	enc := json.NewEncoder(&summaryJson)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(summary); err != nil {
+		logger.WithError(err).Error("failed to summarize aws params")
+		return ""
+	}
+	return strings.TrimSuffix(summaryJson.String(), "\n")
+}
+
+// isZeroParam checks if an input field is unset, the SDKs leave unset
+// fields nil and some fields have no pointer types
+func isZeroParam(field reflect.Value) bool {
+	switch field.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		return field.IsNil()
+	}
+	return field.IsZero()
+}
+
+func truncate(value string, limit int) string {
+	if len(value) <= limit {
+		return value
+	}
+	return value[:limit]
+}
@@ -0,0 +1,80 @@
+package awsoperation
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/lumigo-io/go-tracer-beta/internal/telemetry"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type putItemInput struct {
+	TableName *string
+	Item      map[string]string
+}
+
+func TestInputResourceName(t *testing.T) {
+	assert.Equal(t, "orders", inputResourceName(&putItemInput{TableName: aws.String("orders")}))
+	assert.Equal(t, "bucket", inputResourceName(struct{ Bucket string }{Bucket: "bucket"}))
+	assert.Empty(t, inputResourceName(&putItemInput{}))
+	assert.Empty(t, inputResourceName((*putItemInput)(nil)))
+	assert.Empty(t, inputResourceName(nil))
+	assert.Empty(t, inputResourceName("orders"))
+}
+
+func TestServiceName(t *testing.T) {
+	assert.Equal(t, "dynamodb", ServiceName("DynamoDB"))
+	assert.Equal(t, "events", ServiceName("EventBridge"))
+	assert.Equal(t, "servicecatalog", ServiceName("Service Catalog"))
+}
+
+func TestParamsSummary(t *testing.T) {
+	defer Configure(logger, isSecret)
+	Configure(logger, func(name string) bool { return strings.Contains(name, "Secret") })
+
+	type input struct {
+		_            struct{}
+		TableName    *string
+		Limit        *int32
+		Consistent   bool
+		Items        []string
+		SecretString *string
+		Body         *strings.Reader
+		Unset        *string
+		internal     string
+	}
+	summary := paramsSummary(&input{
+		TableName:    aws.String(strings.Repeat("a", 100)),
+		Limit:        aws.Int32(10),
+		Consistent:   true,
+		Items:        []string{"a", "b"},
+		SecretString: aws.String("s3cr3t"),
+		Body:         strings.NewReader("<html>"),
+		internal:     "internal",
+	})
+	assert.Equal(t, `{"Body":"<strings.Reader>","Consistent":true,"Items":"<2 items>","Limit":10,"SecretString":"****","TableName":"`+strings.Repeat("a", maxParamLength)+`"}`, summary)
+	assert.Empty(t, paramsSummary(&input{}))
+	assert.Empty(t, paramsSummary(nil))
+}
+
+func TestStartSpanIsNotHttpSpan(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	previousProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	defer otel.SetTracerProvider(previousProvider)
+
+	_, span := StartSpan(context.Background(), "dynamodb", "PutItem", "us-east-1", &putItemInput{TableName: aws.String("orders")})
+	EndSpan(span, "req-1", 200, 1, nil)
+
+	// the operation spans are client spans of the lumigo tracer too,
+	// but not the spans of the lumigo Transport
+	spans := sr.Ended()
+	assert.Len(t, spans, 1)
+	assert.False(t, telemetry.IsHttpSpan(spans[0]))
+	assert.False(t, telemetry.IsStartSpan(spans[0]))
+}
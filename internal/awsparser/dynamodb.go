@@ -0,0 +1,173 @@
+package awsparser
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+)
+
+// dynamodbWriteRequest the fields of the DynamoDB write operations
+// identifying the written items
+type dynamodbWriteRequest struct {
+	TableName    string          `json:"TableName"`
+	Item         json.RawMessage `json:"Item"`
+	Key          json.RawMessage `json:"Key"`
+	RequestItems map[string][]struct {
+		PutRequest *struct {
+			Item json.RawMessage `json:"Item"`
+		} `json:"PutRequest"`
+		DeleteRequest *struct {
+			Key json.RawMessage `json:"Key"`
+		} `json:"DeleteRequest"`
+	} `json:"RequestItems"`
+	TransactItems []struct {
+		Put            *dynamodbTransactItem `json:"Put"`
+		Update         *dynamodbTransactItem `json:"Update"`
+		Delete         *dynamodbTransactItem `json:"Delete"`
+		ConditionCheck *dynamodbTransactItem `json:"ConditionCheck"`
+	} `json:"TransactItems"`
+}
+
+type dynamodbTransactItem struct {
+	TableName string          `json:"TableName"`
+	Item      json.RawMessage `json:"Item"`
+	Key       json.RawMessage `json:"Key"`
+}
+
+// keySchemas the key attribute names of the tables, as configured
+// with SetKeySchemas
+var keySchemas struct {
+	sync.RWMutex
+	tables map[string][]string
+}
+
+// SetKeySchemas sets the key attribute names of the tables, the partition
+// key and the sort key if they have one, replacing the previous ones
+func SetKeySchemas(schemas map[string][]string) {
+	tables := make(map[string][]string, len(schemas))
+	for table, attributes := range schemas {
+		if table == "" || len(attributes) == 0 {
+			continue
+		}
+		tables[table] = append([]string{}, attributes...)
+	}
+	keySchemas.Lock()
+	defer keySchemas.Unlock()
+	keySchemas.tables = tables
+}
+
+// itemKey returns the key attributes of an item, nil if the key
+// schema of the table is unknown or the item lacks them
+func itemKey(table string, item json.RawMessage) json.RawMessage {
+	keySchemas.RLock()
+	names, ok := keySchemas.tables[table]
+	keySchemas.RUnlock()
+	if !ok {
+		return nil
+	}
+	var attributes map[string]json.RawMessage
+	if err := json.Unmarshal(item, &attributes); err != nil {
+		return nil
+	}
+	key := make(map[string]json.RawMessage, len(names))
+	for _, name := range names {
+		value, ok := attributes[name]
+		if !ok {
+			return nil
+		}
+		key[name] = value
+	}
+	keyJSON, err := json.Marshal(key)
+	if err != nil {
+		return nil
+	}
+	return keyJSON
+}
+
+// dynamodbItemHashes hashes the keys of the items written by a DynamoDB
+// call, the same way ItemHash is applied to the keys of the stream records
+// they produce. Puts carry whole items, their keys are selected with the
+// configured key schema of the table. Without one, the whole item is hashed,
+// which only matches the new image of the stream records.
+func dynamodbItemHashes(operation string, body []byte) []string {
+	switch operation {
+	case "PutItem", "UpdateItem", "DeleteItem", "BatchWriteItem", "TransactWriteItems":
+	default:
+		return nil
+	}
+	var req dynamodbWriteRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil
+	}
+
+	var hashes []string
+	add := func(table string, key json.RawMessage) {
+		if hash := ItemHash(table, key); hash != "" {
+			hashes = append(hashes, hash)
+		}
+	}
+	put := func(table string, item json.RawMessage) {
+		if key := itemKey(table, item); key != nil {
+			add(table, key)
+			return
+		}
+		add(table, item)
+	}
+	switch operation {
+	case "PutItem":
+		put(req.TableName, req.Item)
+	case "UpdateItem", "DeleteItem":
+		add(req.TableName, req.Key)
+	case "BatchWriteItem":
+		for table, writes := range req.RequestItems {
+			for _, write := range writes {
+				if write.PutRequest != nil {
+					put(table, write.PutRequest.Item)
+				}
+				if write.DeleteRequest != nil {
+					add(table, write.DeleteRequest.Key)
+				}
+			}
+		}
+	case "TransactWriteItems":
+		for _, item := range req.TransactItems {
+			if item.Put != nil {
+				put(item.Put.TableName, item.Put.Item)
+			}
+			if item.Update != nil {
+				add(item.Update.TableName, item.Update.Key)
+			}
+			if item.Delete != nil {
+				add(item.Delete.TableName, item.Delete.Key)
+			}
+		}
+	}
+	return hashes
+}
+
+// ItemHash a deterministic hash of a table name and a DynamoDB item
+// or key in attribute value json, which does not depend on the order
+// of the attributes
+func ItemHash(table string, item json.RawMessage) string {
+	if table == "" || len(item) == 0 {
+		return ""
+	}
+	decoder := json.NewDecoder(bytes.NewReader(item))
+	decoder.UseNumber()
+	var attributes map[string]interface{}
+	if err := decoder.Decode(&attributes); err != nil || len(attributes) == 0 {
+		return ""
+	}
+	// maps are marshaled with sorted keys
+	canonical, err := json.Marshal(attributes)
+	if err != nil {
+		return ""
+	}
+	hash := sha256.New()
+	hash.Write([]byte(table))
+	hash.Write([]byte{0})
+	hash.Write(canonical)
+	return hex.EncodeToString(hash.Sum(nil))
+}
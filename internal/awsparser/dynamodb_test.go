@@ -0,0 +1,115 @@
+package awsparser
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestItemHash(t *testing.T) {
+	hash := ItemHash("orders", json.RawMessage(`{"id":{"S":"1"},"sort":{"N":"2"}}`))
+	assert.Len(t, hash, 64)
+	assert.Equal(t, hash, ItemHash("orders", json.RawMessage(`{ "sort": {"N": "2"}, "id": {"S": "1"} }`)))
+	assert.NotEqual(t, hash, ItemHash("users", json.RawMessage(`{"id":{"S":"1"},"sort":{"N":"2"}}`)))
+	assert.NotEqual(t, hash, ItemHash("orders", json.RawMessage(`{"id":{"S":"2"},"sort":{"N":"2"}}`)))
+	assert.Empty(t, ItemHash("", json.RawMessage(`{"id":{"S":"1"}}`)))
+	assert.Empty(t, ItemHash("orders", json.RawMessage(`{"id":{"S":"1"`)))
+	assert.Empty(t, ItemHash("orders", nil))
+}
+
+func TestDynamodbItemHashes(t *testing.T) {
+	item := json.RawMessage(`{"id":{"S":"1"},"total":{"N":"10"}}`)
+	key := json.RawMessage(`{"id":{"S":"1"}}`)
+	testcases := []struct {
+		testname  string
+		schemas   map[string][]string
+		operation string
+		body      string
+		expected  []string
+	}{
+		{
+			testname:  "put item with unknown key schema",
+			operation: "PutItem",
+			body:      `{"Item":{"id":{"S":"1"},"total":{"N":"10"}},"TableName":"orders"}`,
+			expected:  []string{ItemHash("orders", item)},
+		},
+		{
+			testname:  "put item",
+			schemas:   map[string][]string{"orders": {"id"}},
+			operation: "PutItem",
+			body:      `{"Item":{"id":{"S":"1"},"total":{"N":"10"}},"TableName":"orders"}`,
+			expected:  []string{ItemHash("orders", key)},
+		},
+		{
+			testname:  "put item with a composite key",
+			schemas:   map[string][]string{"orders": {"id", "total"}},
+			operation: "PutItem",
+			body:      `{"Item":{"id":{"S":"1"},"total":{"N":"10"},"note":{"S":"gift"}},"TableName":"orders"}`,
+			expected:  []string{ItemHash("orders", item)},
+		},
+		{
+			testname:  "update item",
+			operation: "UpdateItem",
+			body:      `{"TableName":"orders","Key":{"id":{"S":"1"}},"UpdateExpression":"SET total = :t"}`,
+			expected:  []string{ItemHash("orders", key)},
+		},
+		{
+			testname:  "delete item",
+			operation: "DeleteItem",
+			body:      `{"TableName":"orders","Key":{"id":{"S":"1"}}}`,
+			expected:  []string{ItemHash("orders", key)},
+		},
+		{
+			testname:  "batch write item",
+			schemas:   map[string][]string{"orders": {"id"}},
+			operation: "BatchWriteItem",
+			body:      `{"RequestItems":{"orders":[{"PutRequest":{"Item":{"id":{"S":"1"},"total":{"N":"10"}}}},{"DeleteRequest":{"Key":{"id":{"S":"2"}}}}]}}`,
+			expected:  []string{ItemHash("orders", key), ItemHash("orders", json.RawMessage(`{"id":{"S":"2"}}`))},
+		},
+		{
+			testname:  "transact write items",
+			schemas:   map[string][]string{"orders": {"id"}},
+			operation: "TransactWriteItems",
+			body:      `{"TransactItems":[{"Put":{"TableName":"orders","Item":{"id":{"S":"1"},"total":{"N":"10"}}}},{"Update":{"TableName":"users","Key":{"id":{"S":"1"}}}},{"ConditionCheck":{"TableName":"stock","Key":{"id":{"S":"1"}}}}]}`,
+			expected:  []string{ItemHash("orders", key), ItemHash("users", key)},
+		},
+		{
+			testname:  "read operation",
+			operation: "GetItem",
+			body:      `{"TableName":"orders","Key":{"id":{"S":"1"}}}`,
+		},
+		{
+			testname:  "truncated body",
+			operation: "PutItem",
+			body:      `{"TableName":"orders","Item":{"id":{"S":"1"`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testname, func(t *testing.T) {
+			SetKeySchemas(tc.schemas)
+			assert.Equal(t, tc.expected, dynamodbItemHashes(tc.operation, []byte(tc.body)))
+		})
+	}
+	SetKeySchemas(nil)
+}
+
+func TestDynamodbKeySchemasDoNotDependOnCalls(t *testing.T) {
+	defer SetKeySchemas(nil)
+	SetKeySchemas(nil)
+	item := []byte(`{"TableName":"orders","Item":{"id":{"S":"1"},"total":{"N":"10"}}}`)
+	whole := []string{ItemHash("orders", json.RawMessage(`{"id":{"S":"1"},"total":{"N":"10"}}`))}
+
+	// the keys of the other calls to the table do not change the hashes of its puts
+	assert.Equal(t, whole, dynamodbItemHashes("PutItem", item))
+	dynamodbItemHashes("DeleteItem", []byte(`{"TableName":"orders","Key":{"id":{"S":"2"}}}`))
+	assert.Equal(t, whole, dynamodbItemHashes("PutItem", item))
+
+	SetKeySchemas(map[string][]string{"orders": {"id"}})
+	assert.Equal(t, []string{ItemHash("orders", json.RawMessage(`{"id":{"S":"1"}}`))}, dynamodbItemHashes("PutItem", item))
+
+	// the schemas are replaced as a whole when the config is reloaded
+	SetKeySchemas(map[string][]string{"users": {"id"}})
+	assert.Equal(t, whole, dynamodbItemHashes("PutItem", item))
+}
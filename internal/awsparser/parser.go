@@ -0,0 +1,386 @@
+package awsparser
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Info the details of a call to an AWS service
+type Info struct {
+	Service   string
+	Operation string
+	Region    string
+	// ResourceName the table, bucket, queue URL, topic ARN,
+	// function name etc. the call targets
+	ResourceName string
+	// Key the object key for S3 calls
+	Key string
+	// ItemHashes the hashes of the items written by DynamoDB calls
+	ItemHashes []string
+}
+
+// credentialScope matches the scope of an AWS SigV4 Authorization header
+// e.g. Credential=AKID/20220101/us-east-1/dynamodb/aws4_request
+var credentialScope = regexp.MustCompile(`Credential=[^/]+/\d{8}/([^/]+)/([^/]+)/aws4_request`)
+
+// lambdaInvokePath matches the path of a Lambda Invoke call
+var lambdaInvokePath = regexp.MustCompile(`^/\d{4}-\d{2}-\d{2}/functions/([^/]+)/invocations$`)
+
+// IsAWSRequest checks if the request targets an AWS endpoint
+func IsAWSRequest(req *http.Request) bool {
+	host := req.URL.Hostname()
+	if strings.HasSuffix(host, ".amazonaws.com") || strings.HasSuffix(host, ".amazonaws.com.cn") {
+		return true
+	}
+	return credentialScope.MatchString(req.Header.Get("Authorization"))
+}
+
+// ParsesRequestBody checks if Parse needs the request body to identify
+// the resource of the call, or the items it writes
+func ParsesRequestBody(req *http.Request) bool {
+	switch parseEndpoint(req).Service {
+	case "dynamodb", "sqs", "sns", "kinesis", "events", "states":
+		return true
+	}
+	return false
+}
+
+// Body the request body of an AWS call captured for parsing, the
+// bodies too large to be kept whole are captured by their start and
+// their end, where the SDKs marshal the table, queue or stream after
+// the payload
+type Body struct {
+	Head []byte
+	// Tail the end of the body, nil if Head holds the whole body
+	Tail []byte
+}
+
+// Truncated checks if the middle of the body was not captured
+func (b Body) Truncated() bool {
+	return b.Tail != nil
+}
+
+// stringField finds the first string value of a json field in the
+// captured parts of the body
+func (b Body) stringField(field string) string {
+	return firstNonEmpty(jsonStringField(b.Head, field), jsonStringField(b.Tail, field))
+}
+
+// form parses the captured parts of a form body, the parts lose
+// at most their values cut by the capture
+func (b Body) form() url.Values {
+	form, _ := url.ParseQuery(string(b.Head))
+	if b.Tail != nil {
+		tail, _ := url.ParseQuery(string(b.Tail))
+		for key, values := range tail {
+			if _, ok := form[key]; !ok {
+				form[key] = values
+			}
+		}
+	}
+	return form
+}
+
+// Parse identifies the AWS service, operation, region and resource of
+// a request. The items written by DynamoDB calls are hashed only if
+// the body was captured whole.
+func Parse(req *http.Request, body Body) Info {
+	info := parseEndpoint(req)
+	target := req.Header.Get("X-Amz-Target")
+	if target != "" {
+		// e.g. DynamoDB_20120810.PutItem or AWSEvents.PutEvents
+		info.Operation = target[strings.LastIndex(target, ".")+1:]
+	}
+
+	var form url.Values
+	if isFormBody(req) {
+		form = body.form()
+	}
+	if info.Operation == "" {
+		info.Operation = req.URL.Query().Get("Action")
+	}
+	if info.Operation == "" && form != nil {
+		info.Operation = form.Get("Action")
+	}
+
+	switch info.Service {
+	case "dynamodb":
+		info.ResourceName = dynamodbTableName(body)
+		if !body.Truncated() {
+			info.ItemHashes = dynamodbItemHashes(info.Operation, body.Head)
+		}
+	case "s3":
+		parseS3(req, &info)
+	case "sqs":
+		info.ResourceName = firstNonEmpty(form.Get("QueueUrl"), body.stringField("QueueUrl"))
+	case "sns":
+		info.ResourceName = firstNonEmpty(form.Get("TopicArn"), form.Get("TargetArn"))
+	case "lambda":
+		parseLambda(req, &info)
+	case "kinesis":
+		info.ResourceName = firstNonEmpty(body.stringField("StreamName"), body.stringField("StreamARN"))
+	case "events":
+		if info.Operation == "PutEvents" {
+			info.ResourceName = firstNonEmpty(body.stringField("EventBusName"), "default")
+		}
+	case "states":
+		info.ResourceName = firstNonEmpty(body.stringField("stateMachineArn"), body.stringField("executionArn"))
+	}
+	return info
+}
+
+// parseEndpoint takes the service and region from the SigV4 credential
+// scope, falling back to the host for unsigned requests
+func parseEndpoint(req *http.Request) Info {
+	if match := credentialScope.FindStringSubmatch(req.Header.Get("Authorization")); match != nil {
+		return Info{Region: match[1], Service: match[2]}
+	}
+
+	// e.g. dynamodb.us-east-1.amazonaws.com or bucket.s3.us-east-1.amazonaws.com
+	host := strings.TrimSuffix(strings.TrimSuffix(req.URL.Hostname(), ".cn"), ".amazonaws.com")
+	labels := strings.Split(host, ".")
+	for i, label := range labels {
+		if label == "s3" || strings.HasPrefix(label, "s3-") {
+			info := Info{Service: "s3", Region: strings.TrimPrefix(label, "s3-")}
+			if label == "s3" && i+1 < len(labels) {
+				info.Region = labels[i+1]
+			}
+			if info.Region == "s3" {
+				info.Region = ""
+			}
+			return info
+		}
+	}
+	info := Info{Service: labels[0]}
+	if len(labels) > 1 {
+		info.Region = labels[1]
+	}
+	if info.Service == "queue" {
+		info.Service = "sqs"
+	}
+	return info
+}
+
+func parseS3(req *http.Request, info *Info) {
+	path := strings.TrimPrefix(req.URL.Path, "/")
+	host := req.URL.Hostname()
+	bucket := ""
+	if idx := strings.Index(host, ".s3"); idx > 0 {
+		// virtual hosted style: bucket.s3.region.amazonaws.com
+		bucket = host[:idx]
+	} else if path != "" {
+		// path style: s3.region.amazonaws.com/bucket/key
+		parts := strings.SplitN(path, "/", 2)
+		bucket = parts[0]
+		if len(parts) > 1 {
+			path = parts[1]
+		} else {
+			path = ""
+		}
+	}
+	info.ResourceName = bucket
+	info.Key = path
+	if info.Operation == "" {
+		info.Operation = s3Operation(req.Method, bucket, path)
+	}
+}
+
+func s3Operation(method, bucket, key string) string {
+	switch {
+	case bucket == "":
+		return "ListBuckets"
+	case key == "":
+		switch method {
+		case http.MethodGet:
+			return "ListObjects"
+		case http.MethodPut:
+			return "CreateBucket"
+		case http.MethodDelete:
+			return "DeleteBucket"
+		case http.MethodHead:
+			return "HeadBucket"
+		}
+	default:
+		switch method {
+		case http.MethodGet:
+			return "GetObject"
+		case http.MethodPut:
+			return "PutObject"
+		case http.MethodDelete:
+			return "DeleteObject"
+		case http.MethodHead:
+			return "HeadObject"
+		}
+	}
+	return ""
+}
+
+func parseLambda(req *http.Request, info *Info) {
+	match := lambdaInvokePath.FindStringSubmatch(req.URL.EscapedPath())
+	if match == nil {
+		return
+	}
+	if name, err := url.PathUnescape(match[1]); err == nil {
+		info.ResourceName = name
+	}
+	if info.Operation == "" {
+		info.Operation = "Invoke"
+	}
+}
+
+// dynamodbTableName the table of single item operations or
+// the first table of batch and transaction operations
+func dynamodbTableName(body Body) string {
+	if name := body.stringField("TableName"); name != "" {
+		return name
+	}
+	if match := dynamodbRequestItems.FindSubmatch(body.Head); match != nil {
+		return string(match[1])
+	}
+	return ""
+}
+
+var dynamodbRequestItems = regexp.MustCompile(`"RequestItems"\s*:\s*\{\s*"((?:[^"\\]|\\.)*)"`)
+
+// fieldPatterns caches the compiled patterns of jsonStringField
+var fieldPatterns sync.Map
+
+// jsonStringField finds the first string value of a field in a
+// possibly truncated json document
+func jsonStringField(body []byte, field string) string {
+	pattern, ok := fieldPatterns.Load(field)
+	if !ok {
+		pattern, _ = fieldPatterns.LoadOrStore(field,
+			regexp.MustCompile(`"`+regexp.QuoteMeta(field)+`"\s*:\s*"((?:[^"\\]|\\.)*)"`))
+	}
+	match := pattern.(*regexp.Regexp).FindSubmatch(body)
+	if match == nil {
+		return ""
+	}
+	var value string
+	if err := json.Unmarshal([]byte(`"`+string(match[1])+`"`), &value); err != nil {
+		return string(match[1])
+	}
+	return value
+}
+
+func isFormBody(req *http.Request) bool {
+	return strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded")
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+var (
+	xmlMessageID       = regexp.MustCompile(`<MessageId>([^<]+)</MessageId>`)
+	jsonMessageID      = regexp.MustCompile(`"MessageId"\s*:\s*"([^"]+)"`)
+	jsonEventID        = regexp.MustCompile(`"EventId"\s*:\s*"([^"]+)"`)
+	jsonSequenceNumber = regexp.MustCompile(`"SequenceNumber"\s*:\s*"([^"]+)"`)
+)
+
+// lambdaRequestHeader the response header holding the request ID
+// of a Lambda invocation
+const lambdaRequestHeader = "X-Amzn-Requestid"
+
+// ParseMessageIDs extracts the message, event or record IDs a messaging
+// call returns, which the triggered consumers receive in their events.
+// DynamoDB writes are identified by the hashes of their items instead.
+// The IDs of the response body are found by scanner, nil if it has none.
+func ParseMessageIDs(info Info, header http.Header, scanner *MessageIDScanner) []string {
+	switch {
+	case info.Service == "dynamodb":
+		return info.ItemHashes
+	case info.Service == "lambda" && info.Operation == "Invoke":
+		// the request ID of the invocation is the invoked lambda request ID
+		if requestID := header.Get(lambdaRequestHeader); requestID != "" {
+			return []string{requestID}
+		}
+		return nil
+	}
+	if scanner == nil {
+		return nil
+	}
+	return scanner.IDs()
+}
+
+// maxMessageIDMatch the most bytes a message ID match spans, which
+// the scanner keeps across the writes
+const maxMessageIDMatch = 512
+
+// MessageIDScanner finds the IDs in the response body of a messaging
+// call as it is read, without keeping the body
+type MessageIDScanner struct {
+	mu       sync.Mutex
+	patterns []*regexp.Regexp
+	// ids the IDs found by each pattern
+	ids     [][]string
+	pending []byte
+}
+
+// NewMessageIDScanner returns a scanner of the IDs in the response
+// body of the call, nil if the body has none
+func NewMessageIDScanner(info Info) *MessageIDScanner {
+	patterns := messageIDPatterns(info)
+	if patterns == nil {
+		return nil
+	}
+	return &MessageIDScanner{patterns: patterns, ids: make([][]string, len(patterns))}
+}
+
+func (s *MessageIDScanner) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = append(s.pending, p...)
+	scanned := 0
+	for i, re := range s.patterns {
+		for _, match := range re.FindAllSubmatchIndex(s.pending, -1) {
+			s.ids[i] = append(s.ids[i], string(s.pending[match[2]:match[3]]))
+			if match[1] > scanned {
+				scanned = match[1]
+			}
+		}
+	}
+	// keep the bytes the next write may complete a match with
+	if keep := len(s.pending) - maxMessageIDMatch; keep > scanned {
+		scanned = keep
+	}
+	s.pending = append(s.pending[:0], s.pending[scanned:]...)
+	return len(p), nil
+}
+
+// IDs returns the IDs found by the first pattern matching the body
+func (s *MessageIDScanner) IDs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ids := range s.ids {
+		if len(ids) > 0 {
+			return append([]string{}, ids...)
+		}
+	}
+	return nil
+}
+
+// messageIDPatterns the patterns of the IDs in the response body
+// of a messaging call, nil for other calls
+func messageIDPatterns(info Info) []*regexp.Regexp {
+	switch {
+	case info.Service == "sqs" && (info.Operation == "SendMessage" || info.Operation == "SendMessageBatch"),
+		info.Service == "sns" && info.Operation == "Publish":
+		return []*regexp.Regexp{xmlMessageID, jsonMessageID}
+	case info.Service == "events" && info.Operation == "PutEvents":
+		return []*regexp.Regexp{jsonEventID}
+	case info.Service == "kinesis" && (info.Operation == "PutRecord" || info.Operation == "PutRecords"):
+		return []*regexp.Regexp{jsonSequenceNumber}
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package awsparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newRequest(t *testing.T, method, url string, headers map[string]string) *http.Request {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return req
+}
+
+func TestIsAWSRequest(t *testing.T) {
+	assert.True(t, IsAWSRequest(newRequest(t, http.MethodPost, "https://dynamodb.us-east-1.amazonaws.com/", nil)))
+	assert.True(t, IsAWSRequest(newRequest(t, http.MethodPost, "https://s3.cn-north-1.amazonaws.com.cn/", nil)))
+	assert.True(t, IsAWSRequest(newRequest(t, http.MethodPost, "http://localhost:4566/", map[string]string{
+		"Authorization": "AWS4-HMAC-SHA256 Credential=AKID/20220101/us-east-1/sqs/aws4_request, SignedHeaders=host, Signature=abc",
+	})))
+	assert.False(t, IsAWSRequest(newRequest(t, http.MethodGet, "https://example.com/", nil)))
+}
+
+func TestParse(t *testing.T) {
+	testcases := []struct {
+		testname string
+		method   string
+		url      string
+		headers  map[string]string
+		body     string
+		expected Info
+	}{
+		{
+			testname: "dynamodb put item",
+			method:   http.MethodPost,
+			url:      "https://dynamodb.us-east-1.amazonaws.com/",
+			headers:  map[string]string{"X-Amz-Target": "DynamoDB_20120810.PutItem"},
+			body:     `{"Item":{"id":{"S":"1"}},"TableName":"orders"}`,
+			expected: Info{Service: "dynamodb", Region: "us-east-1", Operation: "PutItem", ResourceName: "orders",
+				ItemHashes: []string{ItemHash("orders", json.RawMessage(`{"id":{"S":"1"}}`))}},
+		},
+		{
+			testname: "dynamodb batch write truncated",
+			method:   http.MethodPost,
+			url:      "https://dynamodb.eu-west-1.amazonaws.com/",
+			headers:  map[string]string{"X-Amz-Target": "DynamoDB_20120810.BatchWriteItem"},
+			body:     `{"RequestItems":{"orders":[{"PutRequest":{"Item":{"id":{"S":"1"`,
+			expected: Info{Service: "dynamodb", Region: "eu-west-1", Operation: "BatchWriteItem", ResourceName: "orders"},
+		},
+		{
+			testname: "signed request to a custom endpoint",
+			method:   http.MethodPost,
+			url:      "http://localhost:8000/",
+			headers: map[string]string{
+				"Authorization": "AWS4-HMAC-SHA256 Credential=AKID/20220101/eu-central-1/dynamodb/aws4_request, SignedHeaders=host, Signature=abc",
+				"X-Amz-Target":  "DynamoDB_20120810.GetItem",
+			},
+			body:     `{"TableName":"users"}`,
+			expected: Info{Service: "dynamodb", Region: "eu-central-1", Operation: "GetItem", ResourceName: "users"},
+		},
+		{
+			testname: "sqs query protocol",
+			method:   http.MethodPost,
+			url:      "https://sqs.us-east-1.amazonaws.com/",
+			headers:  map[string]string{"Content-Type": "application/x-www-form-urlencoded; charset=utf-8"},
+			body:     "Action=SendMessage&MessageBody=hello&QueueUrl=https%3A%2F%2Fsqs.us-east-1.amazonaws.com%2F123456789012%2Fqueue&Version=2012-11-05",
+			expected: Info{Service: "sqs", Region: "us-east-1", Operation: "SendMessage", ResourceName: "https://sqs.us-east-1.amazonaws.com/123456789012/queue"},
+		},
+		{
+			testname: "sqs json protocol",
+			method:   http.MethodPost,
+			url:      "https://sqs.us-east-1.amazonaws.com/",
+			headers:  map[string]string{"X-Amz-Target": "AmazonSQS.SendMessage"},
+			body:     `{"QueueUrl":"https:\/\/sqs.us-east-1.amazonaws.com\/123456789012\/queue","MessageBody":"hello"}`,
+			expected: Info{Service: "sqs", Region: "us-east-1", Operation: "SendMessage", ResourceName: "https://sqs.us-east-1.amazonaws.com/123456789012/queue"},
+		},
+		{
+			testname: "sns publish",
+			method:   http.MethodPost,
+			url:      "https://sns.us-west-2.amazonaws.com/",
+			headers:  map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			body:     "Action=Publish&Message=hello&TopicArn=arn%3Aaws%3Asns%3Aus-west-2%3A123456789012%3Atopic",
+			expected: Info{Service: "sns", Region: "us-west-2", Operation: "Publish", ResourceName: "arn:aws:sns:us-west-2:123456789012:topic"},
+		},
+		{
+			testname: "action in query",
+			method:   http.MethodGet,
+			url:      "https://sts.amazonaws.com/?Action=GetCallerIdentity&Version=2011-06-15",
+			expected: Info{Service: "sts", Operation: "GetCallerIdentity"},
+		},
+		{
+			testname: "lambda invoke",
+			method:   http.MethodPost,
+			url:      "https://lambda.us-east-1.amazonaws.com/2015-03-31/functions/my-function/invocations",
+			expected: Info{Service: "lambda", Region: "us-east-1", Operation: "Invoke", ResourceName: "my-function"},
+		},
+		{
+			testname: "lambda invoke by arn",
+			method:   http.MethodPost,
+			url:      "https://lambda.us-east-1.amazonaws.com/2015-03-31/functions/arn%3Aaws%3Alambda%3Aus-east-1%3A123456789012%3Afunction%3Amy-function/invocations",
+			expected: Info{Service: "lambda", Region: "us-east-1", Operation: "Invoke", ResourceName: "arn:aws:lambda:us-east-1:123456789012:function:my-function"},
+		},
+		{
+			testname: "s3 virtual hosted get object",
+			method:   http.MethodGet,
+			url:      "https://my-bucket.s3.us-east-1.amazonaws.com/path/to/key.json",
+			expected: Info{Service: "s3", Region: "us-east-1", Operation: "GetObject", ResourceName: "my-bucket", Key: "path/to/key.json"},
+		},
+		{
+			testname: "s3 path style put object",
+			method:   http.MethodPut,
+			url:      "https://s3.eu-west-1.amazonaws.com/my-bucket/key",
+			expected: Info{Service: "s3", Region: "eu-west-1", Operation: "PutObject", ResourceName: "my-bucket", Key: "key"},
+		},
+		{
+			testname: "s3 legacy list buckets",
+			method:   http.MethodGet,
+			url:      "https://s3.amazonaws.com/",
+			expected: Info{Service: "s3", Operation: "ListBuckets"},
+		},
+		{
+			testname: "s3 legacy dash region create bucket",
+			method:   http.MethodPut,
+			url:      "https://s3-us-west-2.amazonaws.com/my-bucket",
+			expected: Info{Service: "s3", Region: "us-west-2", Operation: "CreateBucket", ResourceName: "my-bucket"},
+		},
+		{
+			testname: "kinesis put record",
+			method:   http.MethodPost,
+			url:      "https://kinesis.us-east-1.amazonaws.com/",
+			headers:  map[string]string{"X-Amz-Target": "Kinesis_20131202.PutRecord"},
+			body:     `{"Data":"aGVsbG8=","PartitionKey":"1","StreamName":"stream"}`,
+			expected: Info{Service: "kinesis", Region: "us-east-1", Operation: "PutRecord", ResourceName: "stream"},
+		},
+		{
+			testname: "eventbridge put events default bus",
+			method:   http.MethodPost,
+			url:      "https://events.us-east-1.amazonaws.com/",
+			headers:  map[string]string{"X-Amz-Target": "AWSEvents.PutEvents"},
+			body:     `{"Entries":[{"Detail":"{}","DetailType":"test","Source":"app"}]}`,
+			expected: Info{Service: "events", Region: "us-east-1", Operation: "PutEvents", ResourceName: "default"},
+		},
+		{
+			testname: "legacy sqs host",
+			method:   http.MethodPost,
+			url:      "https://queue.amazonaws.com/",
+			expected: Info{Service: "sqs"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testname, func(t *testing.T) {
+			req := newRequest(t, tc.method, tc.url, tc.headers)
+			assert.Equal(t, tc.expected, Parse(req, Body{Head: []byte(tc.body)}))
+		})
+	}
+}
+
+func TestJSONStringField(t *testing.T) {
+	body := []byte(`{"Key":{"TableName":{"S":"nested"}},"TableName" : "orders \"v2\""`)
+	assert.Equal(t, `orders "v2"`, jsonStringField(body, "TableName"))
+	assert.Equal(t, "", jsonStringField(body, "QueueUrl"))
+	assert.Equal(t, "", jsonStringField([]byte(strings.Repeat("a", 10)), "TableName"))
+}
+
+func TestParseMessageIDs(t *testing.T) {
+	testcases := []struct {
+		testname string
+		info     Info
+		header   http.Header
+		body     string
+		expected []string
+	}{
+		{
+			testname: "sqs send message xml",
+			info:     Info{Service: "sqs", Operation: "SendMessage"},
+			body:     `<SendMessageResponse><SendMessageResult><MD5OfMessageBody>abc</MD5OfMessageBody><MessageId>5fea7756-0ea4-451a-a703-a558b933e274</MessageId></SendMessageResult></SendMessageResponse>`,
+			expected: []string{"5fea7756-0ea4-451a-a703-a558b933e274"},
+		},
+		{
+			testname: "sqs send message batch json",
+			info:     Info{Service: "sqs", Operation: "SendMessageBatch"},
+			body:     `{"Successful":[{"Id":"1","MessageId":"a"},{"Id":"2","MessageId":"b"}]}`,
+			expected: []string{"a", "b"},
+		},
+		{
+			testname: "sns publish",
+			info:     Info{Service: "sns", Operation: "Publish"},
+			body:     `<PublishResponse><PublishResult><MessageId>94f20ce6-13c5-43a0-9a9e-ca52d816e90b</MessageId></PublishResult></PublishResponse>`,
+			expected: []string{"94f20ce6-13c5-43a0-9a9e-ca52d816e90b"},
+		},
+		{
+			testname: "eventbridge put events",
+			info:     Info{Service: "events", Operation: "PutEvents"},
+			body:     `{"Entries":[{"EventId":"11710aed-b79e-4468-a20b-bb3c0c3b4860"},{"EventId":"d804d26a-88db-4b66-9eaf-9a11c708ae82"}],"FailedEntryCount":0}`,
+			expected: []string{"11710aed-b79e-4468-a20b-bb3c0c3b4860", "d804d26a-88db-4b66-9eaf-9a11c708ae82"},
+		},
+		{
+			testname: "kinesis put records",
+			info:     Info{Service: "kinesis", Operation: "PutRecords"},
+			body:     `{"FailedRecordCount":0,"Records":[{"SequenceNumber":"4954","ShardId":"shardId-000000000000"},{"SequenceNumber":"4955","ShardId":"shardId-000000000001"}]}`,
+			expected: []string{"4954", "4955"},
+		},
+		{
+			testname: "lambda invoke",
+			info:     Info{Service: "lambda", Operation: "Invoke"},
+			header:   http.Header{"X-Amzn-Requestid": []string{"8ef5a7e8-4a3d-4b9c-9a2a-0d0f6d7e0c1a"}},
+			expected: []string{"8ef5a7e8-4a3d-4b9c-9a2a-0d0f6d7e0c1a"},
+		},
+		{
+			testname: "other operation",
+			info:     Info{Service: "sqs", Operation: "ReceiveMessage"},
+			body:     `<MessageId>a</MessageId>`,
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testname, func(t *testing.T) {
+			header := tc.header
+			if header == nil {
+				header = http.Header{}
+			}
+			scanner := NewMessageIDScanner(tc.info)
+			if scanner != nil {
+				// the body is scanned as it is read, a byte at a time here
+				for i := range tc.body {
+					_, _ = scanner.Write([]byte{tc.body[i]})
+				}
+			}
+			assert.Equal(t, tc.expected, ParseMessageIDs(tc.info, header, scanner))
+		})
+	}
+}
+
+func TestMessageIDScannerLargeBody(t *testing.T) {
+	scanner := NewMessageIDScanner(Info{Service: "kinesis", Operation: "PutRecords"})
+	var expected []string
+	_, _ = scanner.Write([]byte(`{"FailedRecordCount":0,"Records":[`))
+	for i := 0; i < 500; i++ {
+		id := fmt.Sprintf("49546986683135544286507457936321625675700192471156785154%04d", i)
+		expected = append(expected, id)
+		_, _ = scanner.Write([]byte(fmt.Sprintf(`{"SequenceNumber":"%s","ShardId":"shardId-000000000000"},`, id)))
+	}
+	_, _ = scanner.Write([]byte(`]}`))
+	assert.Equal(t, expected, scanner.IDs())
+	// only the bytes a match may span are kept
+	assert.LessOrEqual(t, len(scanner.pending), maxMessageIDMatch)
+}
+
+func TestParseTruncatedBody(t *testing.T) {
+	body := Body{
+		Head: []byte(`{"Item":{"id":{"S":"1"},"note":{"S":"aaa`),
+		Tail: []byte(`aaa"}},"TableName":"orders"}`),
+	}
+	req := newRequest(t, http.MethodPost, "https://dynamodb.us-east-1.amazonaws.com/", map[string]string{"X-Amz-Target": "DynamoDB_20120810.PutItem"})
+	// the resource is found at the end of the body, but a partial item is not hashed
+	assert.Equal(t, Info{Service: "dynamodb", Region: "us-east-1", Operation: "PutItem", ResourceName: "orders"}, Parse(req, body))
+
+	body = Body{
+		Head: []byte(`Action=SendMessage&MessageBody=aaa`),
+		Tail: []byte(`aaa&QueueUrl=https%3A%2F%2Fsqs.us-east-1.amazonaws.com%2F123456789012%2Forders`),
+	}
+	req = newRequest(t, http.MethodPost, "https://sqs.us-east-1.amazonaws.com/", map[string]string{"Content-Type": "application/x-www-form-urlencoded"})
+	assert.Equal(t, Info{Service: "sqs", Region: "us-east-1", Operation: "SendMessage", ResourceName: "https://sqs.us-east-1.amazonaws.com/123456789012/orders"}, Parse(req, body))
+}
@@ -12,6 +12,10 @@ var lumigoKey = &key{}
 // LumigoContext is the set of metadata that is passed for every Invoke.
 type LumigoContext struct {
 	TracerVersion string
+
+	// TransactionID the transaction of the invocation, an invoked
+	// lambda joins the transaction of its caller
+	TransactionID string
 }
 
 // NewContext returns a new Context that carries value lumigo context.
@@ -3,9 +3,14 @@ package telemetry
 import (
 	"os"
 
+	"go.opentelemetry.io/otel/attribute"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
+// HttpSpanKey marks the spans of the outgoing HTTP calls of the lumigo
+// Transport, which may be named after the request
+const HttpSpanKey = attribute.Key("lumigo.http_span")
+
 // SpanTraceRoot the amazon X-Trace-ID
 type SpanTraceRoot struct {
 	Root string `json:"Root"`
@@ -24,13 +29,98 @@ type SpanInfo struct {
 	TraceID       SpanTraceRoot `json:"traceId"`
 	TracerVersion TracerVersion `json:"tracer"`
 	HttpInfo      *SpanHttpInfo `json:"httpInfo,omitempty"`
+	AwsInfo       *SpanAwsInfo  `json:"awsInfo,omitempty"`
+	Trigger       *SpanTrigger  `json:"trigger,omitempty"`
+
+	DbInfo        *SpanDbInfo        `json:"dbInfo,omitempty"`
+	RpcInfo       *SpanRpcInfo       `json:"rpcInfo,omitempty"`
+	MessagingInfo *SpanMessagingInfo `json:"messagingInfo,omitempty"`
+	FaasInfo      *SpanFaasInfo      `json:"faasInfo,omitempty"`
+	GenericInfo   *SpanGenericInfo   `json:"genericInfo,omitempty"`
+
+	// CustomInfo the domain-specific fields set by registered mappers
+	CustomInfo map[string]interface{} `json:"customInfo,omitempty"`
+}
+
+// SpanDbInfo extra info for database calls
+type SpanDbInfo struct {
+	System    string `json:"system"`
+	Name      string `json:"name,omitempty"`
+	Statement string `json:"statement,omitempty"`
+	Operation string `json:"operation,omitempty"`
+	Table     string `json:"table,omitempty"`
+	Host      string `json:"host,omitempty"`
+}
+
+// SpanRpcInfo extra info for RPC calls other than to AWS services
+type SpanRpcInfo struct {
+	System     string `json:"system"`
+	Service    string `json:"service,omitempty"`
+	Method     string `json:"method,omitempty"`
+	StatusCode *int64 `json:"statusCode,omitempty"`
+	Host       string `json:"host,omitempty"`
+}
+
+// SpanMessagingInfo extra info for messages sent or received
+type SpanMessagingInfo struct {
+	System          string `json:"system"`
+	Destination     string `json:"destination,omitempty"`
+	DestinationKind string `json:"destinationKind,omitempty"`
+	Operation       string `json:"operation,omitempty"`
+	MessageID       string `json:"messageId,omitempty"`
+	Host            string `json:"host,omitempty"`
+}
+
+// SpanFaasInfo extra info for invocations of other functions
+type SpanFaasInfo struct {
+	InvokedName     string `json:"invokedName,omitempty"`
+	InvokedProvider string `json:"invokedProvider,omitempty"`
+	InvokedRegion   string `json:"invokedRegion,omitempty"`
+	Trigger         string `json:"trigger,omitempty"`
+}
+
+// SpanGenericInfo the name, kind and attributes of spans
+// without a known semantic convention
+type SpanGenericInfo struct {
+	Name       string                 `json:"name"`
+	Kind       string                 `json:"kind,omitempty"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// SpanTrigger the service which triggered the lambda and the
+// IDs of the messages it delivered
+type SpanTrigger struct {
+	TriggeredBy string   `json:"triggeredBy"`
+	Arn         string   `json:"arn,omitempty"`
+	MessageIDs  []string `json:"messageIds,omitempty"`
+}
+
+// SpanAwsInfo extra info for HTTP requests to AWS services
+type SpanAwsInfo struct {
+	Service      string   `json:"service"`
+	Operation    string   `json:"operation,omitempty"`
+	Region       string   `json:"region,omitempty"`
+	ResourceName string   `json:"resourceName,omitempty"`
+	Key          string   `json:"key,omitempty"`
+	MessageIDs   []string `json:"messageIds,omitempty"`
 }
 
 // SpanHttpInfo extra info for HTTP reuquests
 type SpanHttpInfo struct {
-	Host     string         `json:"host"`
-	Request  SpanHttpCommon `json:"request"`
-	Response SpanHttpCommon `json:"response"`
+	Host     string          `json:"host"`
+	Request  SpanHttpCommon  `json:"request"`
+	Response SpanHttpCommon  `json:"response"`
+	Timing   *SpanHttpTiming `json:"timing,omitempty"`
+}
+
+// SpanHttpTiming the connection phases durations of
+// an HTTP request in milliseconds
+type SpanHttpTiming struct {
+	DNS              float64 `json:"dns,omitempty"`
+	Connect          float64 `json:"connect,omitempty"`
+	TLS              float64 `json:"tls,omitempty"`
+	TimeToFirstByte  float64 `json:"ttfb,omitempty"`
+	ConnectionReused bool    `json:"connectionReused"`
 }
 
 // SpanHttpRequest the span for the HTTP request
@@ -43,6 +133,21 @@ type SpanHttpCommon struct {
 	Headers    string  `json:"headers,omitempty"`
 }
 
+// SpanEvent an event recorded during a span, its timestamp
+// in milliseconds
+type SpanEvent struct {
+	Name       string                 `json:"name"`
+	Timestamp  int64                  `json:"timestamp"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// SpanLink a link to a span of this or another trace
+type SpanLink struct {
+	TraceID    string                 `json:"traceId"`
+	SpanID     string                 `json:"spanId"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
 // SpanError the extra info if lambda returned
 // an error
 type SpanError struct {
@@ -123,8 +228,29 @@ type Span struct {
 
 	// SpanError error details
 	SpanError *SpanError `json:"error"`
+
+	// Events the events recorded during this span, such as exceptions
+	Events []SpanEvent `json:"events,omitempty"`
+
+	// Links the spans this span is causally linked to, such as
+	// the producers of the messages of a batch
+	Links []SpanLink `json:"links,omitempty"`
 }
 
 func IsStartSpan(span sdktrace.ReadOnlySpan) bool {
-	return span.Name() == os.Getenv("AWS_LAMBDA_FUNCTION_NAME") || span.Name() == "HttpSpan"
+	return span.Name() == os.Getenv("AWS_LAMBDA_FUNCTION_NAME") || IsHttpSpan(span)
+}
+
+// IsHttpSpan checks if the span tracks an outgoing HTTP call of the
+// lumigo Transport, by its default name or its HttpSpanKey marker
+func IsHttpSpan(span sdktrace.ReadOnlySpan) bool {
+	if span.Name() == "HttpSpan" {
+		return true
+	}
+	for _, kv := range span.Attributes() {
+		if kv.Key == HttpSpanKey {
+			return kv.Value.AsBool()
+		}
+	}
+	return false
 }
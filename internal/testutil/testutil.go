@@ -0,0 +1,33 @@
+// Package testutil holds the helpers shared by the tests of the tracer
+// and of its subpackages
+package testutil
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Traceparent the W3C trace context the tests propagate
+const Traceparent = "00-0102030405060708090a0b0c0d0e0f10-0102030405060708-01"
+
+// AttributesMap indexes span attributes by their keys
+func AttributesMap(attrs []attribute.KeyValue) map[attribute.Key]attribute.Value {
+	values := make(map[attribute.Key]attribute.Value, len(attrs))
+	for _, kv := range attrs {
+		values[kv.Key] = kv.Value
+	}
+	return values
+}
+
+// WithTraceContextPropagator sets the W3C trace context propagator for
+// the duration of the test and returns a context of Traceparent
+func WithTraceContextPropagator(t testing.TB) context.Context {
+	previous := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(previous) })
+	return otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier{"traceparent": Traceparent})
+}
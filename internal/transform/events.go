@@ -0,0 +1,71 @@
+package transform
+
+import (
+	"github.com/lumigo-io/go-tracer-beta/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+// attributesToMap returns the attributes by key, nil if there are none
+func attributesToMap(kvs []attribute.KeyValue) map[string]interface{} {
+	if len(kvs) == 0 {
+		return nil
+	}
+	attrs := make(map[string]interface{}, len(kvs))
+	for _, kv := range kvs {
+		attrs[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	return attrs
+}
+
+func getEvents(events []sdktrace.Event) []telemetry.SpanEvent {
+	if len(events) == 0 {
+		return nil
+	}
+	spanEvents := make([]telemetry.SpanEvent, 0, len(events))
+	for _, event := range events {
+		spanEvents = append(spanEvents, telemetry.SpanEvent{
+			Name:       event.Name,
+			Timestamp:  event.Time.UnixMilli(),
+			Attributes: attributesToMap(event.Attributes),
+		})
+	}
+	return spanEvents
+}
+
+func getLinks(links []sdktrace.Link) []telemetry.SpanLink {
+	if len(links) == 0 {
+		return nil
+	}
+	spanLinks := make([]telemetry.SpanLink, 0, len(links))
+	for _, link := range links {
+		spanLinks = append(spanLinks, telemetry.SpanLink{
+			TraceID:    link.SpanContext.TraceID().String(),
+			SpanID:     link.SpanContext.SpanID().String(),
+			Attributes: attributesToMap(link.Attributes),
+		})
+	}
+	return spanLinks
+}
+
+// getExceptionError returns the error of the last exception event,
+// as recorded by span.RecordError, nil if there is none
+func getExceptionError(events []sdktrace.Event) *telemetry.SpanError {
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Name != semconv.ExceptionEventName {
+			continue
+		}
+		attrs := attributesToMap(events[i].Attributes)
+		spanError := telemetry.SpanError{
+			Type:       getString(attrs, string(semconv.ExceptionTypeKey)),
+			Message:    getString(attrs, string(semconv.ExceptionMessageKey)),
+			Stacktrace: getString(attrs, string(semconv.ExceptionStacktraceKey)),
+		}
+		if spanError.IsEmpty() {
+			return nil
+		}
+		return &spanError
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package transform
+
+import (
+	"sync"
+
+	"github.com/lumigo-io/go-tracer-beta/internal/telemetry"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// SpanMatcher reports whether a registered mapper applies to a span
+type SpanMatcher func(span sdktrace.ReadOnlySpan) bool
+
+// SpanMapperFunc produces or enriches the fields of the Lumigo span
+// mapped from an OpenTelemetry span
+type SpanMapperFunc func(span sdktrace.ReadOnlySpan, lumigoSpan *telemetry.Span)
+
+type registeredMapper struct {
+	match  SpanMatcher
+	mapper SpanMapperFunc
+}
+
+var (
+	// mappersMu guards the registered mappers
+	mappersMu sync.RWMutex
+	mappers   []registeredMapper
+)
+
+// RegisterMapper registers a mapper applied to the spans matched by
+// match, after the built-in mapping and after the mappers registered
+// before it
+func RegisterMapper(match SpanMatcher, mapper SpanMapperFunc) {
+	mappersMu.Lock()
+	defer mappersMu.Unlock()
+	mappers = append(mappers, registeredMapper{match: match, mapper: mapper})
+}
+
+// MatchName matches the spans with the given name
+func MatchName(name string) SpanMatcher {
+	return func(span sdktrace.ReadOnlySpan) bool {
+		return span.Name() == name
+	}
+}
+
+// MatchAttribute matches the spans having the attribute key with a
+// value satisfying predicate, or with any value if predicate is nil
+func MatchAttribute(key attribute.Key, predicate func(attribute.Value) bool) SpanMatcher {
+	return func(span sdktrace.ReadOnlySpan) bool {
+		for _, kv := range span.Attributes() {
+			if kv.Key == key {
+				return predicate == nil || predicate(kv.Value)
+			}
+		}
+		return false
+	}
+}
+
+// applyMappers applies the registered mappers matching the span in
+// their registration order, a panicking mapper is skipped
+func applyMappers(span sdktrace.ReadOnlySpan, lumigoSpan *telemetry.Span, logger logrus.FieldLogger) {
+	mappersMu.RLock()
+	registered := mappers
+	mappersMu.RUnlock()
+
+	for _, m := range registered {
+		applyMapper(m, span, lumigoSpan, logger)
+	}
+}
+
+func applyMapper(m registeredMapper, span sdktrace.ReadOnlySpan, lumigoSpan *telemetry.Span, logger logrus.FieldLogger) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("span mapper panicked: %v", r)
+		}
+	}()
+	if m.match == nil || m.match(span) {
+		m.mapper(span, lumigoSpan)
+	}
+}
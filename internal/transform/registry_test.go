@@ -0,0 +1,84 @@
+package transform
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/lumigo-io/go-tracer-beta/internal/telemetry"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRegisterMapper(t *testing.T) {
+	defer func(registered []registeredMapper) {
+		mappers = registered
+	}(mappers)
+	mappers = nil
+
+	t.Setenv("AWS_LAMBDA_FUNCTION_NAME", "test")
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	ctx := lambdacontext.NewContext(context.Background(), &mockLambdaContext)
+
+	RegisterMapper(MatchAttribute("rpc.system", func(value attribute.Value) bool {
+		return value.AsString() == "orders-rpc"
+	}), func(span sdktrace.ReadOnlySpan, lumigoSpan *telemetry.Span) {
+		lumigoSpan.LambdaType = "orders-rpc"
+		lumigoSpan.SpanInfo.RpcInfo = nil
+		lumigoSpan.SpanInfo.CustomInfo = map[string]interface{}{"order": "o1"}
+	})
+	RegisterMapper(MatchName("panicking"), func(sdktrace.ReadOnlySpan, *telemetry.Span) {
+		panic("nil map")
+	})
+	// the mappers are applied in their registration order
+	RegisterMapper(MatchAttribute("queue.name", nil), func(span sdktrace.ReadOnlySpan, lumigoSpan *telemetry.Span) {
+		lumigoSpan.SpanInfo.CustomInfo["queue"] = "orders"
+	})
+
+	testcases := []struct {
+		testname   string
+		input      tracetest.SpanStub
+		expectType string
+		expectInfo map[string]interface{}
+	}{
+		{
+			testname: "matched attribute value",
+			input: tracetest.SpanStub{Name: "Get", Attributes: []attribute.KeyValue{
+				attribute.String("rpc.system", "orders-rpc"),
+			}},
+			expectType: "orders-rpc",
+			expectInfo: map[string]interface{}{"order": "o1"},
+		},
+		{
+			testname: "matched in order",
+			input: tracetest.SpanStub{Name: "Get", Attributes: []attribute.KeyValue{
+				attribute.String("rpc.system", "orders-rpc"),
+				attribute.String("queue.name", "orders"),
+			}},
+			expectType: "orders-rpc",
+			expectInfo: map[string]interface{}{"order": "o1", "queue": "orders"},
+		},
+		{
+			testname: "unmatched attribute value",
+			input: tracetest.SpanStub{Name: "Get", Attributes: []attribute.KeyValue{
+				attribute.String("rpc.system", "grpc"),
+			}},
+			expectType: "rpc",
+		},
+		{
+			testname:   "panicking mapper skipped",
+			input:      tracetest.SpanStub{Name: "panicking"},
+			expectType: "generic",
+		},
+	}
+	for _, tc := range testcases {
+		lumigoSpan := NewMapper(ctx, tc.input.Snapshot(), logger).Transform()
+		assert.Equal(t, tc.expectType, lumigoSpan.LambdaType, tc.testname)
+		assert.Equal(t, tc.expectInfo, lumigoSpan.SpanInfo.CustomInfo, tc.testname)
+	}
+}
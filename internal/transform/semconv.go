@@ -0,0 +1,169 @@
+package transform
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/lumigo-io/go-tracer-beta/internal/telemetry"
+	apitrace "go.opentelemetry.io/otel/trace"
+)
+
+// the Lumigo span types
+const (
+	functionType  = "function"
+	httpType      = "http"
+	dbType        = "db"
+	rpcType       = "rpc"
+	messagingType = "messaging"
+	faasType      = "faas"
+	genericType   = "generic"
+)
+
+// getSpanType returns the Lumigo span type matching the semantic
+// conventions of the span attributes, the resource attributes are
+// not considered since they describe the function itself
+func (m *mapper) getSpanType() string {
+	name := m.span.Name()
+	if name == os.Getenv("AWS_LAMBDA_FUNCTION_NAME") || name == "LumigoParentSpan" {
+		return functionType
+	}
+	attrs := attributesToMap(m.span.Attributes())
+	switch {
+	// AWS SDK operation spans are HTTP spans with AWS info
+	case attrs["rpc.system"] == "aws-api":
+		return httpType
+	case hasAttribute(attrs, "http.method"), telemetry.IsHttpSpan(m.span):
+		return httpType
+	case hasAttribute(attrs, "db.system"):
+		return dbType
+	case hasAttribute(attrs, "rpc.system"):
+		return rpcType
+	case hasAttribute(attrs, "messaging.system"):
+		return messagingType
+	case hasAttribute(attrs, "faas.invoked_name"), hasAttribute(attrs, "faas.trigger"):
+		return faasType
+	}
+	return genericType
+}
+
+func hasAttribute(attrs map[string]interface{}, key string) bool {
+	_, ok := attrs[key]
+	return ok
+}
+
+// getString returns the attribute as a string, empty if it is missing
+func getString(attrs map[string]interface{}, key string) string {
+	value, ok := attrs[key]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// getInt64 returns the integer attribute, nil if it is missing
+func getInt64(attrs map[string]interface{}, key string) *int64 {
+	switch value := attrs[key].(type) {
+	case int64:
+		return &value
+	case float64:
+		converted := int64(value)
+		return &converted
+	}
+	return nil
+}
+
+// getPeerHost returns the host and port of the remote peer
+func getPeerHost(attrs map[string]interface{}) string {
+	host := getString(attrs, "net.peer.name")
+	if host == "" {
+		host = getString(attrs, "net.peer.ip")
+	}
+	if port := getInt64(attrs, "net.peer.port"); host != "" && port != nil {
+		return fmt.Sprintf("%s:%d", host, *port)
+	}
+	return host
+}
+
+// getHTTPHostAndURI returns the host and the URI without scheme of an
+// HTTP request, from http.host and http.target as the lumigo Transport
+// sets them or from http.url and the peer as other instrumentations do
+func getHTTPHostAndURI(attrs map[string]interface{}) (string, string) {
+	host := getString(attrs, "http.host")
+	target, hasTarget := attrs["http.target"]
+	if rawURL := getString(attrs, "http.url"); rawURL != "" {
+		if u, err := url.Parse(rawURL); err == nil {
+			if host == "" {
+				host = u.Host
+			}
+			if !hasTarget {
+				target = u.RequestURI()
+			}
+		}
+	}
+	if host == "" {
+		host = getPeerHost(attrs)
+	}
+	if target == nil {
+		target = ""
+	}
+	if host == "" && target == "" {
+		return "", ""
+	}
+	return host, fmt.Sprint(host, target)
+}
+
+func getDbInfo(attrs map[string]interface{}) *telemetry.SpanDbInfo {
+	return &telemetry.SpanDbInfo{
+		System:    getString(attrs, "db.system"),
+		Name:      getString(attrs, "db.name"),
+		Statement: getString(attrs, "db.statement"),
+		Operation: getString(attrs, "db.operation"),
+		Table:     getString(attrs, "db.sql.table"),
+		Host:      getPeerHost(attrs),
+	}
+}
+
+func getRpcInfo(attrs map[string]interface{}) *telemetry.SpanRpcInfo {
+	return &telemetry.SpanRpcInfo{
+		System:     getString(attrs, "rpc.system"),
+		Service:    getString(attrs, "rpc.service"),
+		Method:     getString(attrs, "rpc.method"),
+		StatusCode: getInt64(attrs, "rpc.grpc.status_code"),
+		Host:       getPeerHost(attrs),
+	}
+}
+
+func getMessagingInfo(attrs map[string]interface{}) *telemetry.SpanMessagingInfo {
+	return &telemetry.SpanMessagingInfo{
+		System:          getString(attrs, "messaging.system"),
+		Destination:     getString(attrs, "messaging.destination"),
+		DestinationKind: getString(attrs, "messaging.destination_kind"),
+		Operation:       getString(attrs, "messaging.operation"),
+		MessageID:       getString(attrs, "messaging.message_id"),
+		Host:            getPeerHost(attrs),
+	}
+}
+
+func getFaasInfo(attrs map[string]interface{}) *telemetry.SpanFaasInfo {
+	return &telemetry.SpanFaasInfo{
+		InvokedName:     getString(attrs, "faas.invoked_name"),
+		InvokedProvider: getString(attrs, "faas.invoked_provider"),
+		InvokedRegion:   getString(attrs, "faas.invoked_region"),
+		Trigger:         getString(attrs, "faas.trigger"),
+	}
+}
+
+// getGenericInfo keeps all the span attributes, without the
+// resource ones which every span shares
+func (m *mapper) getGenericInfo() *telemetry.SpanGenericInfo {
+	info := telemetry.SpanGenericInfo{
+		Name: m.span.Name(),
+	}
+	if m.span.SpanKind() != apitrace.SpanKindUnspecified {
+		info.Kind = strings.ToLower(m.span.SpanKind().String())
+	}
+	info.Attributes = attributesToMap(m.span.Attributes())
+	return &info
+}
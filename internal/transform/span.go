@@ -82,10 +82,24 @@ func (m *mapper) Transform() telemetry.Span {
 		},
 	}
 
-	lambdaType := "function"
-	if m.span.Name() != lumigoSpan.LambdaName && m.span.Name() != "LumigoParentSpan" {
-		lambdaType = "http"
-		lumigoSpan.SpanInfo.HttpInfo = m.getHTTPInfo(attrs)
+	lambdaType := m.getSpanType()
+	switch lambdaType {
+	case httpType:
+		// AWS SDK operation spans wrap the HTTP spans of their attempts
+		if _, ok := attrs["http.method"]; ok {
+			lumigoSpan.SpanInfo.HttpInfo = m.getHTTPInfo(attrs)
+		}
+		lumigoSpan.SpanInfo.AwsInfo = getAwsInfo(attrs)
+	case dbType:
+		lumigoSpan.SpanInfo.DbInfo = getDbInfo(attrs)
+	case rpcType:
+		lumigoSpan.SpanInfo.RpcInfo = getRpcInfo(attrs)
+	case messagingType:
+		lumigoSpan.SpanInfo.MessagingInfo = getMessagingInfo(attrs)
+	case faasType:
+		lumigoSpan.SpanInfo.FaasInfo = getFaasInfo(attrs)
+	case genericType:
+		lumigoSpan.SpanInfo.GenericInfo = m.getGenericInfo()
 	}
 	lumigoSpan.LambdaType = lambdaType
 
@@ -94,11 +108,11 @@ func (m *mapper) Transform() telemetry.Span {
 		containerID, _ := uuid.NewUUID()
 		lumigoSpan.LambdaContainerID = containerID.String()
 
-		if lambdaType == "http" {
+		if lambdaType == functionType {
+			lumigoSpan.ID = lambdaCtx.AwsRequestID
+		} else {
 			spanID, _ := uuid.NewUUID()
 			lumigoSpan.ID = spanID.String()
-		} else {
-			lumigoSpan.ID = lambdaCtx.AwsRequestID
 		}
 
 		if isStartSpan {
@@ -125,23 +139,30 @@ func (m *mapper) Transform() telemetry.Span {
 
 	if event, ok := attrs["event"]; ok {
 		lumigoSpan.Event = fmt.Sprint(event)
+		if lambdaType == functionType {
+			lumigoSpan.SpanInfo.Trigger = getTrigger(lumigoSpan.Event)
+		}
 	} else {
 		m.logger.Error("unable to fetch lambda event from span")
 	}
 
 	if returnValue, ok := attrs["response"]; ok {
 		lumigoSpan.LambdaResponse = aws.String(fmt.Sprint(returnValue))
-	} else if !isStartSpan {
+	} else if lambdaType == functionType && !isStartSpan {
 		m.logger.Error("unable to fetch lambda response from span")
 	}
 
-	if transactionID := getTransactionID(awsRoot); transactionID != "" {
+	lumigoCtx, lumigoOk := lumigoctx.FromContext(m.ctx)
+	transactionID := getTransactionID(awsRoot)
+	if lumigoOk && lumigoCtx.TransactionID != "" {
+		transactionID = lumigoCtx.TransactionID
+	}
+	if transactionID != "" {
 		lumigoSpan.TransactionID = transactionID
 	} else {
 		m.logger.Error("unable to fetch transaction ID")
 	}
 
-	lumigoCtx, lumigoOk := lumigoctx.FromContext(m.ctx)
 	if lumigoOk {
 		lumigoSpan.SpanInfo.TracerVersion = telemetry.TracerVersion{
 			Version: lumigoCtx.TracerVersion,
@@ -160,7 +181,15 @@ func (m *mapper) Transform() telemetry.Span {
 	if !isStartSpan {
 		lumigoSpan.SpanError = m.getSpanError(attrs)
 	}
+	// instrumentations other than the lumigo ones record their errors
+	// as exception events
+	if lumigoSpan.SpanError == nil && lambdaType != functionType {
+		lumigoSpan.SpanError = getExceptionError(m.span.Events())
+	}
+	lumigoSpan.Events = getEvents(m.span.Events())
+	lumigoSpan.Links = getLinks(m.span.Links())
 	lumigoSpan.LambdaEnvVars = m.getEnvVars()
+	applyMappers(m.span, &lumigoSpan, m.logger)
 	return lumigoSpan
 }
 
@@ -205,12 +234,16 @@ func (m *mapper) getEnvVars() string {
 	return string(envsString)
 }
 
+// getHTTPInfo the request and response details are optional, the
+// lumigo Transport captures them but other instrumentations do not
 func (m *mapper) getHTTPInfo(attrs map[string]interface{}) *telemetry.SpanHttpInfo {
 	var spanHttpInfo telemetry.SpanHttpInfo
-	if host, ok := attrs["http.host"]; ok {
-		spanHttpInfo.Host = fmt.Sprint(host)
+	host, uri := getHTTPHostAndURI(attrs)
+	spanHttpInfo.Host = host
+	if uri != "" {
+		spanHttpInfo.Request.URI = aws.String(uri)
 	} else {
-		m.logger.Error("unable to fetch HTTP host")
+		m.logger.Error("unable to fetch HTTP target")
 	}
 
 	if method, ok := attrs["http.method"]; ok {
@@ -219,45 +252,76 @@ func (m *mapper) getHTTPInfo(attrs map[string]interface{}) *telemetry.SpanHttpIn
 		m.logger.Error("unable to fetch HTTP method")
 	}
 
-	if target, ok := attrs["http.target"]; ok {
-		uri := fmt.Sprintf("%s%s", spanHttpInfo.Host, target)
-		spanHttpInfo.Request.URI = aws.String(uri)
-	} else {
-		m.logger.Error("unable to fetch HTTP target")
-	}
-
 	if headers, ok := attrs["http.request_headers"]; ok {
 		spanHttpInfo.Request.Headers = fmt.Sprint(headers)
-	} else {
-		m.logger.Error("unable to fetch HTTP request headers")
 	}
-
 	if reqBody, ok := attrs["http.request_body"]; ok {
 		spanHttpInfo.Request.Body = fmt.Sprint(reqBody)
-	} else {
-		m.logger.Error("unable to fetch HTTP request body")
 	}
 
+	// response
 	if headers, ok := attrs["http.response_headers"]; ok {
 		spanHttpInfo.Response.Headers = fmt.Sprint(headers)
-	} else {
-		m.logger.Error("unable to fetch HTTP response headers")
 	}
-
-	// response
 	if respBody, ok := attrs["http.response_body"]; ok {
 		spanHttpInfo.Response.Body = fmt.Sprint(respBody)
-	} else {
-		m.logger.Error("unable to fetch HTTP response body")
 	}
+	spanHttpInfo.Response.StatusCode = getInt64(attrs, "http.status_code")
 
-	if code, ok := attrs["http.status_code"]; ok {
-		spanHttpInfo.Response.StatusCode = aws.Int64(code.(int64))
-	} else {
-		m.logger.Error("unable to fetch HTTP status code")
+	spanHttpInfo.Timing = getHTTPTiming(attrs)
+	return &spanHttpInfo
+}
+
+// getHTTPTiming the timing is optional, an HTTP span has it only
+// when the request reached the connection phase
+func getHTTPTiming(attrs map[string]interface{}) *telemetry.SpanHttpTiming {
+	reused, ok := attrs["http.timing.connection_reused"].(bool)
+	if !ok {
+		return nil
 	}
+	timing := telemetry.SpanHttpTiming{
+		ConnectionReused: reused,
+	}
+	if dns, ok := attrs["http.timing.dns"].(float64); ok {
+		timing.DNS = dns
+	}
+	if connect, ok := attrs["http.timing.connect"].(float64); ok {
+		timing.Connect = connect
+	}
+	if tls, ok := attrs["http.timing.tls"].(float64); ok {
+		timing.TLS = tls
+	}
+	if ttfb, ok := attrs["http.timing.ttfb"].(float64); ok {
+		timing.TimeToFirstByte = ttfb
+	}
+	return &timing
+}
 
-	return &spanHttpInfo
+// getAwsInfo the AWS info is set only for calls to AWS services
+func getAwsInfo(attrs map[string]interface{}) *telemetry.SpanAwsInfo {
+	if system, ok := attrs["rpc.system"]; !ok || system != "aws-api" {
+		return nil
+	}
+	var awsInfo telemetry.SpanAwsInfo
+	if service, ok := attrs["rpc.service"]; ok {
+		awsInfo.Service = fmt.Sprint(service)
+	}
+	if operation, ok := attrs["rpc.method"]; ok {
+		awsInfo.Operation = fmt.Sprint(operation)
+	}
+	if region, ok := attrs["aws.region"]; ok {
+		awsInfo.Region = fmt.Sprint(region)
+	}
+	if resourceName, ok := attrs["aws.resource_name"]; ok {
+		awsInfo.ResourceName = fmt.Sprint(resourceName)
+	}
+	if key, ok := attrs["aws.s3.key"]; ok {
+		awsInfo.Key = fmt.Sprint(key)
+	}
+	if messageIDs, ok := attrs["aws.message_ids"].([]string); ok {
+		awsInfo.MessageIDs = messageIDs
+	}
+	return &awsInfo
 }
 
 func isProvisionConcurrencyInitialization() bool {
@@ -281,9 +345,15 @@ func getAmazonTraceID() string {
 	return ""
 }
 
+// TransactionID the transaction ID of the current invocation
+// derived from its Amazon Trace ID
+func TransactionID() string {
+	return getTransactionID(getAmazonTraceID())
+}
+
 func getTransactionID(root string) string {
 	items := strings.SplitN(root, "-", 3)
-	if len(items) > 1 {
+	if len(items) > 2 {
 		return items[2]
 	}
 	return ""
@@ -9,9 +9,11 @@ import (
 
 	"github.com/aws/aws-lambda-go/lambdacontext"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	lumigoctx "github.com/lumigo-io/go-tracer-beta/internal/context"
 	"github.com/lumigo-io/go-tracer-beta/internal/telemetry"
 	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -19,6 +21,8 @@ import (
 var (
 	traceID, _        = trace.TraceIDFromHex("000000")
 	spanID, _         = trace.SpanIDFromHex("000000")
+	linkTraceID, _    = trace.TraceIDFromHex("0af7651916cd43dd8448eb211c80319c")
+	linkSpanID, _     = trace.SpanIDFromHex("b7ad6b7169203331")
 	mockLambdaContext = lambdacontext.LambdaContext{
 		AwsRequestID:       "123",
 		InvokedFunctionArn: "arn:partition:service:region:account-id:resource-type:resource-id",
@@ -338,6 +342,637 @@ func TestTransform(t *testing.T) {
 				os.Unsetenv("IS_WARM_START")
 			},
 		},
+		{
+			testname: "span http with timing",
+			input: &tracetest.SpanStub{
+				SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+					TraceID: traceID,
+					SpanID:  spanID,
+				}),
+				StartTime: now,
+				EndTime:   now.Add(1 * time.Second),
+				Name:      "HttpSpan",
+				Attributes: []attribute.KeyValue{
+					attribute.String("http.host", "s3.aws.com"),
+					attribute.String("http.target", "/"),
+					attribute.String("http.method", "GET"),
+					attribute.Int64("http.status_code", 200),
+					attribute.Bool("http.timing.connection_reused", false),
+					attribute.Float64("http.timing.dns", 1.5),
+					attribute.Float64("http.timing.connect", 2),
+					attribute.Float64("http.timing.tls", 10.25),
+					attribute.Float64("http.timing.ttfb", 40),
+					attribute.String("event", "test"),
+				},
+			},
+			expect: telemetry.Span{
+				LambdaName:       "test",
+				LambdaType:       "http",
+				LambdaReadiness:  "warm",
+				LambdaResponse:   nil,
+				Event:            "test",
+				Account:          "account-id",
+				ID:               mockLambdaContext.AwsRequestID,
+				StartedTimestamp: now.UnixMilli(),
+				EndedTimestamp:   now.Add(1 * time.Second).UnixMilli(),
+				SpanInfo: telemetry.SpanInfo{
+					HttpInfo: &telemetry.SpanHttpInfo{
+						Host: "s3.aws.com",
+						Request: telemetry.SpanHttpCommon{
+							URI:    aws.String("s3.aws.com/"),
+							Method: aws.String("GET"),
+						},
+						Response: telemetry.SpanHttpCommon{
+							StatusCode: aws.Int64(200),
+						},
+						Timing: &telemetry.SpanHttpTiming{
+							DNS:             1.5,
+							Connect:         2,
+							TLS:             10.25,
+							TimeToFirstByte: 40,
+						},
+					},
+				},
+			},
+			before: func() {
+				os.Setenv("AWS_LAMBDA_FUNCTION_NAME", "test")
+				os.Setenv("IS_WARM_START", "true")
+			},
+			after: func() {
+				os.Unsetenv("AWS_LAMBDA_FUNCTION_NAME")
+				os.Unsetenv("IS_WARM_START")
+			},
+		},
+		{
+			testname: "span http to aws",
+			input: &tracetest.SpanStub{
+				SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+					TraceID: traceID,
+					SpanID:  spanID,
+				}),
+				StartTime: now,
+				EndTime:   now.Add(1 * time.Second),
+				Name:      "HttpSpan",
+				Attributes: []attribute.KeyValue{
+					attribute.String("http.host", "dynamodb.us-east-1.amazonaws.com"),
+					attribute.String("http.target", "/"),
+					attribute.String("http.method", "POST"),
+					attribute.Int64("http.status_code", 200),
+					attribute.String("rpc.system", "aws-api"),
+					attribute.String("rpc.service", "dynamodb"),
+					attribute.String("rpc.method", "PutItem"),
+					attribute.String("aws.region", "us-east-1"),
+					attribute.String("aws.resource_name", "orders"),
+					attribute.String("event", "test"),
+				},
+			},
+			expect: telemetry.Span{
+				LambdaName:       "test",
+				LambdaType:       "http",
+				LambdaReadiness:  "warm",
+				LambdaResponse:   nil,
+				Event:            "test",
+				Account:          "account-id",
+				ID:               mockLambdaContext.AwsRequestID,
+				StartedTimestamp: now.UnixMilli(),
+				EndedTimestamp:   now.Add(1 * time.Second).UnixMilli(),
+				SpanInfo: telemetry.SpanInfo{
+					HttpInfo: &telemetry.SpanHttpInfo{
+						Host: "dynamodb.us-east-1.amazonaws.com",
+						Request: telemetry.SpanHttpCommon{
+							URI:    aws.String("dynamodb.us-east-1.amazonaws.com/"),
+							Method: aws.String("POST"),
+						},
+						Response: telemetry.SpanHttpCommon{
+							StatusCode: aws.Int64(200),
+						},
+					},
+					AwsInfo: &telemetry.SpanAwsInfo{
+						Service:      "dynamodb",
+						Operation:    "PutItem",
+						Region:       "us-east-1",
+						ResourceName: "orders",
+					},
+				},
+			},
+			before: func() {
+				os.Setenv("AWS_LAMBDA_FUNCTION_NAME", "test")
+				os.Setenv("IS_WARM_START", "true")
+			},
+			after: func() {
+				os.Unsetenv("AWS_LAMBDA_FUNCTION_NAME")
+				os.Unsetenv("IS_WARM_START")
+			},
+		},
+		{
+			testname: "span http to aws with message ids",
+			input: &tracetest.SpanStub{
+				SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+					TraceID: traceID,
+					SpanID:  spanID,
+				}),
+				StartTime: now,
+				EndTime:   now.Add(1 * time.Second),
+				Name:      "HttpSpan",
+				Attributes: []attribute.KeyValue{
+					attribute.String("http.host", "sqs.us-east-1.amazonaws.com"),
+					attribute.String("http.target", "/"),
+					attribute.String("http.method", "POST"),
+					attribute.Int64("http.status_code", 200),
+					attribute.String("rpc.system", "aws-api"),
+					attribute.String("rpc.service", "sqs"),
+					attribute.String("rpc.method", "SendMessage"),
+					attribute.StringSlice("aws.message_ids", []string{"m1"}),
+					attribute.String("event", "test"),
+				},
+			},
+			expect: telemetry.Span{
+				LambdaName:       "test",
+				LambdaType:       "http",
+				LambdaReadiness:  "warm",
+				LambdaResponse:   nil,
+				Event:            "test",
+				Account:          "account-id",
+				ID:               mockLambdaContext.AwsRequestID,
+				StartedTimestamp: now.UnixMilli(),
+				EndedTimestamp:   now.Add(1 * time.Second).UnixMilli(),
+				SpanInfo: telemetry.SpanInfo{
+					HttpInfo: &telemetry.SpanHttpInfo{
+						Host: "sqs.us-east-1.amazonaws.com",
+						Request: telemetry.SpanHttpCommon{
+							URI:    aws.String("sqs.us-east-1.amazonaws.com/"),
+							Method: aws.String("POST"),
+						},
+						Response: telemetry.SpanHttpCommon{
+							StatusCode: aws.Int64(200),
+						},
+					},
+					AwsInfo: &telemetry.SpanAwsInfo{
+						Service:    "sqs",
+						Operation:  "SendMessage",
+						MessageIDs: []string{"m1"},
+					},
+				},
+			},
+			before: func() {
+				os.Setenv("AWS_LAMBDA_FUNCTION_NAME", "test")
+				os.Setenv("IS_WARM_START", "true")
+			},
+			after: func() {
+				os.Unsetenv("AWS_LAMBDA_FUNCTION_NAME")
+				os.Unsetenv("IS_WARM_START")
+			},
+		},
+		{
+			testname: "span aws sdk operation",
+			input: &tracetest.SpanStub{
+				SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+					TraceID: traceID,
+					SpanID:  spanID,
+				}),
+				StartTime: now,
+				EndTime:   now.Add(1 * time.Second),
+				Name:      "dynamodb.PutItem",
+				Attributes: []attribute.KeyValue{
+					attribute.String("rpc.system", "aws-api"),
+					attribute.String("rpc.service", "dynamodb"),
+					attribute.String("rpc.method", "PutItem"),
+					attribute.String("aws.region", "us-east-1"),
+					attribute.Int64("http.status_code", 200),
+					attribute.String("event", "test"),
+				},
+			},
+			expect: telemetry.Span{
+				LambdaName:       "test",
+				LambdaType:       "http",
+				LambdaReadiness:  "warm",
+				LambdaResponse:   nil,
+				Event:            "test",
+				Account:          "account-id",
+				ID:               mockLambdaContext.AwsRequestID,
+				StartedTimestamp: now.UnixMilli(),
+				EndedTimestamp:   now.Add(1 * time.Second).UnixMilli(),
+				SpanInfo: telemetry.SpanInfo{
+					AwsInfo: &telemetry.SpanAwsInfo{
+						Service:   "dynamodb",
+						Operation: "PutItem",
+						Region:    "us-east-1",
+					},
+				},
+			},
+			before: func() {
+				os.Setenv("AWS_LAMBDA_FUNCTION_NAME", "test")
+				os.Setenv("IS_WARM_START", "true")
+			},
+			after: func() {
+				os.Unsetenv("AWS_LAMBDA_FUNCTION_NAME")
+				os.Unsetenv("IS_WARM_START")
+			},
+		},
+		{
+			testname: "span triggered by sqs",
+			input: &tracetest.SpanStub{
+				SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+					TraceID: traceID,
+					SpanID:  spanID,
+				}),
+				StartTime: now,
+				EndTime:   now.Add(1 * time.Second),
+				Name:      "LumigoParentSpan",
+				Attributes: []attribute.KeyValue{
+					attribute.String("event", `{"Records":[{"messageId":"m1","eventSource":"aws:sqs","eventSourceARN":"arn:aws:sqs:us-east-1:123456789012:orders"}]}`),
+					attribute.String("response", "test2"),
+				},
+			},
+			expect: telemetry.Span{
+				LambdaName:       "test",
+				LambdaType:       "function",
+				LambdaReadiness:  "warm",
+				LambdaResponse:   aws.String("test2"),
+				Event:            `{"Records":[{"messageId":"m1","eventSource":"aws:sqs","eventSourceARN":"arn:aws:sqs:us-east-1:123456789012:orders"}]}`,
+				Account:          "account-id",
+				ID:               mockLambdaContext.AwsRequestID,
+				StartedTimestamp: now.UnixMilli(),
+				EndedTimestamp:   now.Add(1 * time.Second).UnixMilli(),
+				SpanInfo: telemetry.SpanInfo{
+					Trigger: &telemetry.SpanTrigger{
+						TriggeredBy: "sqs",
+						Arn:         "arn:aws:sqs:us-east-1:123456789012:orders",
+						MessageIDs:  []string{"m1"},
+					},
+				},
+			},
+			before: func() {
+				os.Setenv("AWS_LAMBDA_FUNCTION_NAME", "test")
+				os.Setenv("IS_WARM_START", "true")
+			},
+			after: func() {
+				os.Unsetenv("AWS_LAMBDA_FUNCTION_NAME")
+				os.Unsetenv("IS_WARM_START")
+			},
+		},
+		{
+			testname: "span otelhttp client",
+			input: &tracetest.SpanStub{
+				SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+					TraceID: traceID,
+					SpanID:  spanID,
+				}),
+				StartTime: now,
+				EndTime:   now.Add(1 * time.Second),
+				Name:      "operation",
+				SpanKind:  trace.SpanKindClient,
+				Attributes: []attribute.KeyValue{
+					attribute.String("http.method", "GET"),
+					attribute.String("http.url", "https://api.example.com/orders?id=1"),
+					attribute.Int64("http.status_code", 404),
+				},
+			},
+			expect: telemetry.Span{
+				LambdaName:       "test",
+				LambdaType:       "http",
+				LambdaReadiness:  "warm",
+				Account:          "account-id",
+				ID:               mockLambdaContext.AwsRequestID,
+				StartedTimestamp: now.UnixMilli(),
+				EndedTimestamp:   now.Add(1 * time.Second).UnixMilli(),
+				SpanInfo: telemetry.SpanInfo{
+					HttpInfo: &telemetry.SpanHttpInfo{
+						Host: "api.example.com",
+						Request: telemetry.SpanHttpCommon{
+							URI:    aws.String("api.example.com/orders?id=1"),
+							Method: aws.String("GET"),
+						},
+						Response: telemetry.SpanHttpCommon{
+							StatusCode: aws.Int64(404),
+						},
+					},
+				},
+			},
+			before: func() {
+				os.Setenv("AWS_LAMBDA_FUNCTION_NAME", "test")
+				os.Setenv("IS_WARM_START", "true")
+			},
+			after: func() {
+				os.Unsetenv("AWS_LAMBDA_FUNCTION_NAME")
+				os.Unsetenv("IS_WARM_START")
+			},
+		},
+		{
+			testname: "span db",
+			input: &tracetest.SpanStub{
+				SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+					TraceID: traceID,
+					SpanID:  spanID,
+				}),
+				StartTime: now,
+				EndTime:   now.Add(1 * time.Second),
+				Name:      "operation",
+				SpanKind:  trace.SpanKindClient,
+				Attributes: []attribute.KeyValue{
+					attribute.String("db.system", "postgresql"),
+					attribute.String("db.name", "orders"),
+					attribute.String("db.statement", "SELECT * FROM orders"),
+					attribute.String("db.operation", "SELECT"),
+					attribute.String("db.sql.table", "orders"),
+					attribute.String("net.peer.name", "db.example.com"),
+					attribute.Int64("net.peer.port", 5432),
+				},
+			},
+			expect: telemetry.Span{
+				LambdaName:       "test",
+				LambdaType:       "db",
+				LambdaReadiness:  "warm",
+				Account:          "account-id",
+				ID:               mockLambdaContext.AwsRequestID,
+				StartedTimestamp: now.UnixMilli(),
+				EndedTimestamp:   now.Add(1 * time.Second).UnixMilli(),
+				SpanInfo: telemetry.SpanInfo{
+					DbInfo: &telemetry.SpanDbInfo{
+						System:    "postgresql",
+						Name:      "orders",
+						Statement: "SELECT * FROM orders",
+						Operation: "SELECT",
+						Table:     "orders",
+						Host:      "db.example.com:5432",
+					},
+				},
+			},
+			before: func() {
+				os.Setenv("AWS_LAMBDA_FUNCTION_NAME", "test")
+				os.Setenv("IS_WARM_START", "true")
+			},
+			after: func() {
+				os.Unsetenv("AWS_LAMBDA_FUNCTION_NAME")
+				os.Unsetenv("IS_WARM_START")
+			},
+		},
+		{
+			testname: "span grpc",
+			input: &tracetest.SpanStub{
+				SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+					TraceID: traceID,
+					SpanID:  spanID,
+				}),
+				StartTime: now,
+				EndTime:   now.Add(1 * time.Second),
+				Name:      "operation",
+				SpanKind:  trace.SpanKindClient,
+				Attributes: []attribute.KeyValue{
+					attribute.String("rpc.system", "grpc"),
+					attribute.String("rpc.service", "orders.Orders"),
+					attribute.String("rpc.method", "Get"),
+					attribute.Int64("rpc.grpc.status_code", 5),
+					attribute.String("net.peer.ip", "10.0.0.1"),
+				},
+			},
+			expect: telemetry.Span{
+				LambdaName:       "test",
+				LambdaType:       "rpc",
+				LambdaReadiness:  "warm",
+				Account:          "account-id",
+				ID:               mockLambdaContext.AwsRequestID,
+				StartedTimestamp: now.UnixMilli(),
+				EndedTimestamp:   now.Add(1 * time.Second).UnixMilli(),
+				SpanInfo: telemetry.SpanInfo{
+					RpcInfo: &telemetry.SpanRpcInfo{
+						System:     "grpc",
+						Service:    "orders.Orders",
+						Method:     "Get",
+						StatusCode: aws.Int64(5),
+						Host:       "10.0.0.1",
+					},
+				},
+			},
+			before: func() {
+				os.Setenv("AWS_LAMBDA_FUNCTION_NAME", "test")
+				os.Setenv("IS_WARM_START", "true")
+			},
+			after: func() {
+				os.Unsetenv("AWS_LAMBDA_FUNCTION_NAME")
+				os.Unsetenv("IS_WARM_START")
+			},
+		},
+		{
+			testname: "span messaging",
+			input: &tracetest.SpanStub{
+				SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+					TraceID: traceID,
+					SpanID:  spanID,
+				}),
+				StartTime: now,
+				EndTime:   now.Add(1 * time.Second),
+				Name:      "operation",
+				SpanKind:  trace.SpanKindProducer,
+				Attributes: []attribute.KeyValue{
+					attribute.String("messaging.system", "kafka"),
+					attribute.String("messaging.destination", "orders"),
+					attribute.String("messaging.destination_kind", "topic"),
+					attribute.String("messaging.message_id", "m1"),
+				},
+			},
+			expect: telemetry.Span{
+				LambdaName:       "test",
+				LambdaType:       "messaging",
+				LambdaReadiness:  "warm",
+				Account:          "account-id",
+				ID:               mockLambdaContext.AwsRequestID,
+				StartedTimestamp: now.UnixMilli(),
+				EndedTimestamp:   now.Add(1 * time.Second).UnixMilli(),
+				SpanInfo: telemetry.SpanInfo{
+					MessagingInfo: &telemetry.SpanMessagingInfo{
+						System:          "kafka",
+						Destination:     "orders",
+						DestinationKind: "topic",
+						MessageID:       "m1",
+					},
+				},
+			},
+			before: func() {
+				os.Setenv("AWS_LAMBDA_FUNCTION_NAME", "test")
+				os.Setenv("IS_WARM_START", "true")
+			},
+			after: func() {
+				os.Unsetenv("AWS_LAMBDA_FUNCTION_NAME")
+				os.Unsetenv("IS_WARM_START")
+			},
+		},
+		{
+			testname: "span faas invocation",
+			input: &tracetest.SpanStub{
+				SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+					TraceID: traceID,
+					SpanID:  spanID,
+				}),
+				StartTime: now,
+				EndTime:   now.Add(1 * time.Second),
+				Name:      "operation",
+				SpanKind:  trace.SpanKindClient,
+				Attributes: []attribute.KeyValue{
+					attribute.String("faas.invoked_name", "billing"),
+					attribute.String("faas.invoked_provider", "aws"),
+					attribute.String("faas.invoked_region", "us-east-1"),
+				},
+			},
+			expect: telemetry.Span{
+				LambdaName:       "test",
+				LambdaType:       "faas",
+				LambdaReadiness:  "warm",
+				Account:          "account-id",
+				ID:               mockLambdaContext.AwsRequestID,
+				StartedTimestamp: now.UnixMilli(),
+				EndedTimestamp:   now.Add(1 * time.Second).UnixMilli(),
+				SpanInfo: telemetry.SpanInfo{
+					FaasInfo: &telemetry.SpanFaasInfo{
+						InvokedName:     "billing",
+						InvokedProvider: "aws",
+						InvokedRegion:   "us-east-1",
+					},
+				},
+			},
+			before: func() {
+				os.Setenv("AWS_LAMBDA_FUNCTION_NAME", "test")
+				os.Setenv("IS_WARM_START", "true")
+			},
+			after: func() {
+				os.Unsetenv("AWS_LAMBDA_FUNCTION_NAME")
+				os.Unsetenv("IS_WARM_START")
+			},
+		},
+		{
+			testname: "span generic",
+			input: &tracetest.SpanStub{
+				SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+					TraceID: traceID,
+					SpanID:  spanID,
+				}),
+				StartTime: now,
+				EndTime:   now.Add(1 * time.Second),
+				Name:      "operation",
+				SpanKind:  trace.SpanKindInternal,
+				Attributes: []attribute.KeyValue{
+					attribute.String("order.id", "o1"),
+					attribute.Int64("order.items", 3),
+				},
+			},
+			expect: telemetry.Span{
+				LambdaName:       "test",
+				LambdaType:       "generic",
+				LambdaReadiness:  "warm",
+				Account:          "account-id",
+				ID:               mockLambdaContext.AwsRequestID,
+				StartedTimestamp: now.UnixMilli(),
+				EndedTimestamp:   now.Add(1 * time.Second).UnixMilli(),
+				SpanInfo: telemetry.SpanInfo{
+					GenericInfo: &telemetry.SpanGenericInfo{
+						Name: "operation",
+						Kind: "internal",
+						Attributes: map[string]interface{}{
+							"order.id":    "o1",
+							"order.items": int64(3),
+						},
+					},
+				},
+			},
+			before: func() {
+				os.Setenv("AWS_LAMBDA_FUNCTION_NAME", "test")
+				os.Setenv("IS_WARM_START", "true")
+			},
+			after: func() {
+				os.Unsetenv("AWS_LAMBDA_FUNCTION_NAME")
+				os.Unsetenv("IS_WARM_START")
+			},
+		},
+		{
+			testname: "span with exception event and link",
+			input: &tracetest.SpanStub{
+				SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+					TraceID: traceID,
+					SpanID:  spanID,
+				}),
+				StartTime: now,
+				EndTime:   now.Add(1 * time.Second),
+				Name:      "process",
+				SpanKind:  trace.SpanKindConsumer,
+				Attributes: []attribute.KeyValue{
+					attribute.String("messaging.system", "kafka"),
+				},
+				Events: []sdktrace.Event{
+					{
+						Name: "retry",
+						Time: now.Add(100 * time.Millisecond),
+					},
+					{
+						Name: "exception",
+						Time: now.Add(200 * time.Millisecond),
+						Attributes: []attribute.KeyValue{
+							attribute.String("exception.type", "*errors.errorString"),
+							attribute.String("exception.message", "invalid order"),
+						},
+					},
+				},
+				Links: []sdktrace.Link{
+					{
+						SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+							TraceID: linkTraceID,
+							SpanID:  linkSpanID,
+						}),
+						Attributes: []attribute.KeyValue{
+							attribute.String("messaging.message_id", "m1"),
+						},
+					},
+				},
+			},
+			expect: telemetry.Span{
+				LambdaName:       "test",
+				LambdaType:       "messaging",
+				LambdaReadiness:  "warm",
+				Account:          "account-id",
+				ID:               mockLambdaContext.AwsRequestID,
+				StartedTimestamp: now.UnixMilli(),
+				EndedTimestamp:   now.Add(1 * time.Second).UnixMilli(),
+				SpanInfo: telemetry.SpanInfo{
+					MessagingInfo: &telemetry.SpanMessagingInfo{
+						System: "kafka",
+					},
+				},
+				SpanError: &telemetry.SpanError{
+					Type:    "*errors.errorString",
+					Message: "invalid order",
+				},
+				Events: []telemetry.SpanEvent{
+					{
+						Name:      "retry",
+						Timestamp: now.Add(100 * time.Millisecond).UnixMilli(),
+					},
+					{
+						Name:      "exception",
+						Timestamp: now.Add(200 * time.Millisecond).UnixMilli(),
+						Attributes: map[string]interface{}{
+							"exception.type":    "*errors.errorString",
+							"exception.message": "invalid order",
+						},
+					},
+				},
+				Links: []telemetry.SpanLink{
+					{
+						TraceID: "0af7651916cd43dd8448eb211c80319c",
+						SpanID:  "b7ad6b7169203331",
+						Attributes: map[string]interface{}{
+							"messaging.message_id": "m1",
+						},
+					},
+				},
+			},
+			before: func() {
+				os.Setenv("AWS_LAMBDA_FUNCTION_NAME", "test")
+				os.Setenv("IS_WARM_START", "true")
+			},
+			after: func() {
+				os.Unsetenv("AWS_LAMBDA_FUNCTION_NAME")
+				os.Unsetenv("IS_WARM_START")
+			},
+		},
 	}
 
 	for _, tc := range testcases {
@@ -350,7 +985,7 @@ func TestTransform(t *testing.T) {
 		lumigoSpan.LambdaContainerID = ""
 		// intentionally ignore MaxFinishTime, cannot be matched
 		lumigoSpan.MaxFinishTime = 0
-		if lumigoSpan.LambdaType == "http" {
+		if lumigoSpan.LambdaType != "function" {
 			lumigoSpan.ID = mockLambdaContext.AwsRequestID
 		}
 		if !reflect.DeepEqual(lumigoSpan, tc.expect) {
@@ -359,3 +994,22 @@ func TestTransform(t *testing.T) {
 		tc.after()
 	}
 }
+
+func TestTransformTransactionID(t *testing.T) {
+	os.Setenv("_X_AMZN_TRACE_ID", "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1")
+	defer os.Unsetenv("_X_AMZN_TRACE_ID")
+	ctx := lambdacontext.NewContext(context.Background(), &mockLambdaContext)
+	span := &tracetest.SpanStub{Name: "test"}
+
+	lumigoSpan := NewMapper(ctx, span.Snapshot(), logrus.New()).Transform()
+	if lumigoSpan.TransactionID != "bd862e3fe1be46a994272793" {
+		t.Errorf("unexpected transaction ID %s", lumigoSpan.TransactionID)
+	}
+
+	// an invoked lambda joins the transaction of its caller
+	ctx = lumigoctx.NewContext(ctx, &lumigoctx.LumigoContext{TransactionID: "caller"})
+	lumigoSpan = NewMapper(ctx, span.Snapshot(), logrus.New()).Transform()
+	if lumigoSpan.TransactionID != "caller" {
+		t.Errorf("unexpected transaction ID %s", lumigoSpan.TransactionID)
+	}
+}
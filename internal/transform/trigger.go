@@ -0,0 +1,102 @@
+package transform
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/lumigo-io/go-tracer-beta/internal/awsparser"
+	"github.com/lumigo-io/go-tracer-beta/internal/telemetry"
+)
+
+// triggerEvent the fields of the supported lambda events which
+// identify the messages triggering an invocation
+type triggerEvent struct {
+	Records []struct {
+		// sns records name it EventSource, json matches it case insensitively
+		EventSource    string `json:"eventSource"`
+		EventSourceARN string `json:"eventSourceARN"`
+		MessageID      string `json:"messageId"`
+		Sns            struct {
+			MessageID string `json:"MessageId"`
+			TopicArn  string `json:"TopicArn"`
+		} `json:"Sns"`
+		Kinesis struct {
+			SequenceNumber string `json:"sequenceNumber"`
+		} `json:"kinesis"`
+		DynamoDB struct {
+			Keys     json.RawMessage `json:"Keys"`
+			NewImage json.RawMessage `json:"NewImage"`
+		} `json:"dynamodb"`
+	} `json:"Records"`
+
+	// EventBridge events
+	ID         string `json:"id"`
+	DetailType string `json:"detail-type"`
+	Source     string `json:"source"`
+}
+
+// getTrigger detects the service which triggered the invocation from
+// its event and the IDs of the messages it delivered, these match
+// the IDs recorded on the producer HTTP spans
+func getTrigger(event string) *telemetry.SpanTrigger {
+	var e triggerEvent
+	if err := json.Unmarshal([]byte(event), &e); err != nil {
+		return nil
+	}
+
+	if e.ID != "" && e.DetailType != "" && e.Source != "" {
+		return &telemetry.SpanTrigger{
+			TriggeredBy: "eventBridge",
+			MessageIDs:  []string{e.ID},
+		}
+	}
+	if len(e.Records) == 0 {
+		return nil
+	}
+
+	var trigger telemetry.SpanTrigger
+	for _, record := range e.Records {
+		switch record.EventSource {
+		case "aws:sqs":
+			trigger.TriggeredBy = "sqs"
+			trigger.Arn = record.EventSourceARN
+			trigger.MessageIDs = append(trigger.MessageIDs, record.MessageID)
+		case "aws:sns":
+			trigger.TriggeredBy = "sns"
+			trigger.Arn = record.Sns.TopicArn
+			trigger.MessageIDs = append(trigger.MessageIDs, record.Sns.MessageID)
+		case "aws:kinesis":
+			trigger.TriggeredBy = "kinesis"
+			trigger.Arn = record.EventSourceARN
+			trigger.MessageIDs = append(trigger.MessageIDs, record.Kinesis.SequenceNumber)
+		case "aws:dynamodb":
+			trigger.TriggeredBy = "dynamodb"
+			trigger.Arn = record.EventSourceARN
+			trigger.MessageIDs = append(trigger.MessageIDs, dynamodbRecordHashes(record.EventSourceARN, record.DynamoDB.Keys, record.DynamoDB.NewImage)...)
+		}
+	}
+	if trigger.TriggeredBy == "" {
+		return nil
+	}
+	return &trigger
+}
+
+// dynamodbRecordHashes hashes a stream record as its write was hashed,
+// the writes hash their keys, or their whole items for puts to tables
+// whose key schema is not known yet. Both the keys and the new image
+// are hashed since the stream may not carry the images.
+func dynamodbRecordHashes(streamARN string, keys, newImage json.RawMessage) []string {
+	// e.g. arn:aws:dynamodb:us-east-1:123456789012:table/orders/stream/2022-01-01T00:00:00.000
+	parts := strings.Split(streamARN, "/")
+	if len(parts) < 2 {
+		return nil
+	}
+	table := parts[1]
+	var hashes []string
+	for _, item := range []json.RawMessage{newImage, keys} {
+		if hash := awsparser.ItemHash(table, item); hash != "" {
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes
+}
@@ -0,0 +1,81 @@
+package transform
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/lumigo-io/go-tracer-beta/internal/awsparser"
+	"github.com/lumigo-io/go-tracer-beta/internal/telemetry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTrigger(t *testing.T) {
+	testcases := []struct {
+		testname string
+		event    string
+		expect   *telemetry.SpanTrigger
+	}{
+		{
+			testname: "sqs",
+			event:    `{"Records":[{"messageId":"m1","eventSource":"aws:sqs","eventSourceARN":"arn:aws:sqs:us-east-1:123456789012:orders"},{"messageId":"m2","eventSource":"aws:sqs","eventSourceARN":"arn:aws:sqs:us-east-1:123456789012:orders"}]}`,
+			expect: &telemetry.SpanTrigger{
+				TriggeredBy: "sqs",
+				Arn:         "arn:aws:sqs:us-east-1:123456789012:orders",
+				MessageIDs:  []string{"m1", "m2"},
+			},
+		},
+		{
+			testname: "sns",
+			event:    `{"Records":[{"EventSource":"aws:sns","Sns":{"MessageId":"m1","TopicArn":"arn:aws:sns:us-east-1:123456789012:orders"}}]}`,
+			expect: &telemetry.SpanTrigger{
+				TriggeredBy: "sns",
+				Arn:         "arn:aws:sns:us-east-1:123456789012:orders",
+				MessageIDs:  []string{"m1"},
+			},
+		},
+		{
+			testname: "kinesis",
+			event:    `{"Records":[{"eventSource":"aws:kinesis","eventSourceARN":"arn:aws:kinesis:us-east-1:123456789012:stream/orders","kinesis":{"sequenceNumber":"4959"}}]}`,
+			expect: &telemetry.SpanTrigger{
+				TriggeredBy: "kinesis",
+				Arn:         "arn:aws:kinesis:us-east-1:123456789012:stream/orders",
+				MessageIDs:  []string{"4959"},
+			},
+		},
+		{
+			testname: "dynamodb",
+			event:    `{"Records":[{"eventName":"INSERT","eventSource":"aws:dynamodb","eventSourceARN":"arn:aws:dynamodb:us-east-1:123456789012:table/orders/stream/2022-01-01T00:00:00.000","dynamodb":{"Keys":{"id":{"S":"1"}},"NewImage":{"total":{"N":"10"},"id":{"S":"1"}}}},{"eventName":"REMOVE","eventSource":"aws:dynamodb","eventSourceARN":"arn:aws:dynamodb:us-east-1:123456789012:table/orders/stream/2022-01-01T00:00:00.000","dynamodb":{"Keys":{"id":{"S":"2"}}}}]}`,
+			expect: &telemetry.SpanTrigger{
+				TriggeredBy: "dynamodb",
+				Arn:         "arn:aws:dynamodb:us-east-1:123456789012:table/orders/stream/2022-01-01T00:00:00.000",
+				MessageIDs: []string{
+					awsparser.ItemHash("orders", json.RawMessage(`{"id":{"S":"1"},"total":{"N":"10"}}`)),
+					awsparser.ItemHash("orders", json.RawMessage(`{"id":{"S":"1"}}`)),
+					awsparser.ItemHash("orders", json.RawMessage(`{"id":{"S":"2"}}`)),
+				},
+			},
+		},
+		{
+			testname: "eventbridge",
+			event:    `{"id":"e1","detail-type":"OrderCreated","source":"orders","detail":{}}`,
+			expect: &telemetry.SpanTrigger{
+				TriggeredBy: "eventBridge",
+				MessageIDs:  []string{"e1"},
+			},
+		},
+		{
+			testname: "unknown event",
+			event:    `{"name":"test"}`,
+		},
+		{
+			testname: "not json",
+			event:    "test",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testname, func(t *testing.T) {
+			assert.Equal(t, tc.expect, getTrigger(tc.event))
+		})
+	}
+}
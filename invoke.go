@@ -0,0 +1,32 @@
+package lumigotracer
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/lumigo-io/go-tracer-beta/internal/awsoperation"
+	"github.com/lumigo-io/go-tracer-beta/internal/transform"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// extractInvocationContext returns ctx with the trace context the
+// caller of an invocation sent in its ClientContext
+func extractInvocationContext(ctx context.Context) context.Context {
+	lambdaCtx, ok := lambdacontext.FromContext(ctx)
+	if !ok || len(lambdaCtx.ClientContext.Custom) == 0 {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(lambdaCtx.ClientContext.Custom))
+}
+
+// invocationTransactionID the transaction ID of the invocation, which
+// is the caller one if it is known
+func invocationTransactionID(ctx context.Context) string {
+	if lambdaCtx, ok := lambdacontext.FromContext(ctx); ok {
+		if transactionID := lambdaCtx.ClientContext.Custom[awsoperation.TransactionIDKey]; transactionID != "" {
+			return transactionID
+		}
+	}
+	return transform.TransactionID()
+}
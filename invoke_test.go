@@ -0,0 +1,32 @@
+package lumigotracer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/lumigo-io/go-tracer-beta/internal/awsoperation"
+	"github.com/lumigo-io/go-tracer-beta/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestExtractInvocationContext(t *testing.T) {
+	testutil.WithTraceContextPropagator(t)
+
+	ctx := lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{
+		ClientContext: lambdacontext.ClientContext{Custom: map[string]string{
+			"traceparent":                 testutil.Traceparent,
+			awsoperation.TransactionIDKey: "transaction",
+		}},
+	})
+	sc := trace.SpanContextFromContext(extractInvocationContext(ctx))
+	assert.True(t, sc.IsRemote())
+	assert.Equal(t, "0102030405060708090a0b0c0d0e0f10", sc.TraceID().String())
+	assert.Equal(t, "transaction", invocationTransactionID(ctx))
+
+	t.Setenv("_X_AMZN_TRACE_ID", "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1")
+	ctx = lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{})
+	assert.False(t, trace.SpanContextFromContext(extractInvocationContext(ctx)).IsValid())
+	assert.Equal(t, "bd862e3fe1be46a994272793", invocationTransactionID(ctx))
+}
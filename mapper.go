@@ -0,0 +1,59 @@
+package lumigotracer
+
+import (
+	"github.com/lumigo-io/go-tracer-beta/internal/telemetry"
+	"github.com/lumigo-io/go-tracer-beta/internal/transform"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Span the Lumigo span an OpenTelemetry span is mapped to
+type Span = telemetry.Span
+
+// the details of the Lumigo spans which the mappers may set
+type (
+	SpanInfo          = telemetry.SpanInfo
+	SpanHttpInfo      = telemetry.SpanHttpInfo
+	SpanHttpCommon    = telemetry.SpanHttpCommon
+	SpanHttpTiming    = telemetry.SpanHttpTiming
+	SpanAwsInfo       = telemetry.SpanAwsInfo
+	SpanDbInfo        = telemetry.SpanDbInfo
+	SpanRpcInfo       = telemetry.SpanRpcInfo
+	SpanMessagingInfo = telemetry.SpanMessagingInfo
+	SpanFaasInfo      = telemetry.SpanFaasInfo
+	SpanGenericInfo   = telemetry.SpanGenericInfo
+	SpanTrigger       = telemetry.SpanTrigger
+	SpanError         = telemetry.SpanError
+	SpanEvent         = telemetry.SpanEvent
+	SpanLink          = telemetry.SpanLink
+)
+
+// SpanMatcher reports whether a registered mapper applies to a span
+type SpanMatcher = transform.SpanMatcher
+
+// SpanMapper produces or enriches the fields of the Lumigo span
+// mapped from an OpenTelemetry span
+type SpanMapper = transform.SpanMapperFunc
+
+// RegisterSpanMapper registers a mapper applied to the spans matched by
+// match, or to all the spans if match is nil, after the built-in mapping
+// and after the mappers registered before it. A panicking mapper is
+// skipped. Register the mappers before wrapping the handler:
+//
+//	lumigotracer.RegisterSpanMapper(lumigotracer.MatchSpanName("checkout"),
+//		func(span sdktrace.ReadOnlySpan, lumigoSpan *lumigotracer.Span) {
+//			lumigoSpan.SpanInfo.CustomInfo = map[string]interface{}{"cart": "42"}
+//		})
+func RegisterSpanMapper(match SpanMatcher, mapper SpanMapper) {
+	transform.RegisterMapper(match, mapper)
+}
+
+// MatchSpanName matches the spans with the given name
+func MatchSpanName(name string) SpanMatcher {
+	return transform.MatchName(name)
+}
+
+// MatchSpanAttribute matches the spans having the attribute key with a
+// value satisfying predicate, or with any value if predicate is nil
+func MatchSpanAttribute(key attribute.Key, predicate func(attribute.Value) bool) SpanMatcher {
+	return transform.MatchAttribute(key, predicate)
+}
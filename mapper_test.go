@@ -0,0 +1,77 @@
+package lumigotracer_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	lumigotracer "github.com/lumigo-io/go-tracer-beta"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestRegisterSpanMapper(t *testing.T) {
+	t.Setenv("AWS_LAMBDA_FUNCTION_NAME", "testFunction")
+	t.Setenv("AWS_REGION", "us-east-1")
+
+	lumigotracer.RegisterSpanMapper(lumigotracer.MatchSpanName("checkout"),
+		func(span sdktrace.ReadOnlySpan, lumigoSpan *lumigotracer.Span) {
+			lumigoSpan.SpanInfo.CustomInfo = map[string]interface{}{"cart": "42"}
+		})
+	lumigotracer.RegisterSpanMapper(lumigotracer.MatchSpanAttribute("payment.provider", nil),
+		func(span sdktrace.ReadOnlySpan, lumigoSpan *lumigotracer.Span) {
+			lumigoSpan.LambdaType = "payment"
+			lumigoSpan.SpanInfo.RpcInfo = &lumigotracer.SpanRpcInfo{System: "payments", Method: "Charge"}
+		})
+
+	dir := t.TempDir()
+	handler := lumigotracer.WrapHandler(func(ctx context.Context) (string, error) {
+		_, checkout := otel.Tracer("test").Start(ctx, "checkout")
+		checkout.End()
+		_, charge := otel.Tracer("test").Start(ctx, "charge")
+		charge.SetAttributes(attribute.String("payment.provider", "stripe"))
+		charge.End()
+		return "ok", nil
+	}, &lumigotracer.Config{Token: "token", SpansDir: dir})
+
+	ctx := lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{
+		AwsRequestID:       "123",
+		InvokedFunctionArn: "arn:partition:service:region:account-id:resource-type:resource-id",
+	})
+	_, err := handler.(func(context.Context, json.RawMessage) (interface{}, error))(ctx, json.RawMessage(`{}`))
+	assert.NoError(t, err)
+
+	spans := map[string]lumigotracer.Span{}
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	for _, file := range files {
+		if strings.Contains(file.Name(), "_span") {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		assert.NoError(t, err)
+		var fileSpans []lumigotracer.Span
+		assert.NoError(t, json.Unmarshal(content, &fileSpans))
+		for _, span := range fileSpans {
+			if span.SpanInfo.GenericInfo != nil {
+				spans[span.SpanInfo.GenericInfo.Name] = span
+			}
+		}
+	}
+
+	checkout, ok := spans["checkout"]
+	assert.True(t, ok)
+	assert.Equal(t, map[string]interface{}{"cart": "42"}, checkout.SpanInfo.CustomInfo)
+
+	charge, ok := spans["charge"]
+	assert.True(t, ok)
+	assert.Equal(t, "payment", charge.LambdaType)
+	assert.Equal(t, &lumigotracer.SpanRpcInfo{System: "payments", Method: "Charge"}, charge.SpanInfo.RpcInfo)
+	assert.Nil(t, charge.SpanInfo.CustomInfo)
+}
@@ -0,0 +1,58 @@
+package lumigotracer
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/lumigo-io/go-tracer-beta/internal/awsoperation"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// messagingEvent the fields of the SQS, SNS and EventBridge lambda
+// events holding the trace context of the producer
+type messagingEvent struct {
+	Records []struct {
+		MessageAttributes map[string]struct {
+			StringValue string `json:"stringValue"`
+		} `json:"messageAttributes"`
+		Sns struct {
+			MessageAttributes map[string]struct {
+				Value string `json:"Value"`
+			} `json:"MessageAttributes"`
+		} `json:"Sns"`
+	} `json:"Records"`
+	Detail map[string]json.RawMessage `json:"detail"`
+}
+
+// extractMessagingContext returns ctx with the trace context the
+// producer of the messages of a lambda event sent, the first message
+// carrying one is used
+func extractMessagingContext(ctx context.Context, event []byte) context.Context {
+	var e messagingEvent
+	if err := json.Unmarshal(event, &e); err != nil {
+		return ctx
+	}
+	propagator := otel.GetTextMapPropagator()
+	for _, record := range e.Records {
+		carrier := propagation.MapCarrier{}
+		for key, attribute := range record.MessageAttributes {
+			carrier[key] = attribute.StringValue
+		}
+		for key, attribute := range record.Sns.MessageAttributes {
+			carrier[key] = attribute.Value
+		}
+		extracted := propagator.Extract(ctx, carrier)
+		if trace.SpanContextFromContext(extracted).IsRemote() {
+			return extracted
+		}
+	}
+	if traceContext, ok := e.Detail[awsoperation.EventBridgeTraceContextKey]; ok {
+		carrier := propagation.MapCarrier{}
+		if err := json.Unmarshal(traceContext, &carrier); err == nil {
+			return propagator.Extract(ctx, carrier)
+		}
+	}
+	return ctx
+}
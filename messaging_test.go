@@ -0,0 +1,54 @@
+package lumigotracer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lumigo-io/go-tracer-beta/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestExtractMessagingContext(t *testing.T) {
+	testutil.WithTraceContextPropagator(t)
+
+	testcases := []struct {
+		testname string
+		event    string
+		expected bool
+	}{
+		{
+			testname: "sqs",
+			event:    `{"Records":[{"messageId":"1","messageAttributes":{}},{"messageId":"2","messageAttributes":{"traceparent":{"stringValue":"` + testutil.Traceparent + `","dataType":"String"}}}]}`,
+			expected: true,
+		},
+		{
+			testname: "sns",
+			event:    `{"Records":[{"Sns":{"MessageAttributes":{"traceparent":{"Type":"String","Value":"` + testutil.Traceparent + `"}}}}]}`,
+			expected: true,
+		},
+		{
+			testname: "eventbridge",
+			event:    `{"id":"1","detail-type":"OrderCreated","detail":{"_traceContext":{"traceparent":"` + testutil.Traceparent + `"}}}`,
+			expected: true,
+		},
+		{
+			testname: "without trace context",
+			event:    `{"Records":[{"messageId":"1"}]}`,
+		},
+		{
+			testname: "not json",
+			event:    `test`,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testname, func(t *testing.T) {
+			sc := trace.SpanContextFromContext(extractMessagingContext(context.Background(), []byte(tc.event)))
+			assert.Equal(t, tc.expected, sc.IsValid())
+			if tc.expected {
+				assert.Equal(t, "0102030405060708090a0b0c0d0e0f10", sc.TraceID().String())
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+// Package otlp exports the spans to an OpenTelemetry collector. The OTLP
+// exporters import gRPC and protobuf, which grow every binary linking
+// them by megabytes and slow down the cold starts, so the tracer leaves
+// them to the lambdas importing this package. Importing it registers the
+// lumigotracer.OTLPSpansExporter, configured by the environment:
+//
+//	import _ "github.com/lumigo-io/go-tracer-beta/otlp"
+//
+//	lumigotracer.WrapHandler(handler, &lumigotracer.Config{
+//	  SpansExporters: []string{lumigotracer.ExtensionSpansExporter, lumigotracer.OTLPSpansExporter},
+//	})
+//
+// or an Exporter with explicit options is passed to the tracer:
+//
+//	exporter, err := otlp.New(context.Background(), otlp.WithEndpoint("http://collector:4318/v1/traces"))
+//	lumigotracer.WrapHandler(handler, &lumigotracer.Config{
+//	  Exporters: []sdktrace.SpanExporter{exporter},
+//	})
+package otlp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	lumigotracer "github.com/lumigo-io/go-tracer-beta"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+const (
+	// HTTPProtocol sends the spans with OTLP over HTTP
+	HTTPProtocol = "http/protobuf"
+	// GRPCProtocol sends the spans with OTLP over gRPC
+	GRPCProtocol = "grpc"
+)
+
+// defaultTimeout the most time an export to the collector takes
+const defaultTimeout = 10 * time.Second
+
+// lumigoAttributes the Lumigo attributes without a semantic convention
+// and the names they are sent with over OTLP
+var lumigoAttributes = map[attribute.Key]attribute.Key{
+	"event":              "lumigo.event",
+	"response":           "lumigo.response",
+	"http.request_body":  "lumigo.http.request_body",
+	"http.response_body": "lumigo.http.response_body",
+}
+
+// lumigoHeadersAttributes the Lumigo json headers attributes and the
+// prefix of the semantic convention attribute of each header
+var lumigoHeadersAttributes = map[attribute.Key]string{
+	"http.request_headers":  "http.request.header.",
+	"http.response_headers": "http.response.header.",
+}
+
+// Exporter sends the spans to an OpenTelemetry collector, with the
+// Lumigo attributes mapped onto the semantic conventions. It is created
+// once and kept across the invocations.
+type Exporter struct {
+	exporter *otlptrace.Exporter
+	endpoint string
+	protocol string
+	timeout  time.Duration
+}
+
+// Option configures an Exporter
+type Option func(*Exporter)
+
+// WithEndpoint sets the URL of the collector, e.g.
+// http://collector:4318/v1/traces, the OTEL_EXPORTER_OTLP_* environment
+// variables configure the exporter if it is empty
+func WithEndpoint(endpoint string) Option {
+	return func(e *Exporter) {
+		e.endpoint = endpoint
+	}
+}
+
+// WithProtocol sets the protocol of the exporter, either HTTPProtocol,
+// the default, or GRPCProtocol
+func WithProtocol(protocol string) Option {
+	return func(e *Exporter) {
+		e.protocol = protocol
+	}
+}
+
+// WithTimeout sets the most time an export takes, 10 seconds by default
+func WithTimeout(timeout time.Duration) Option {
+	return func(e *Exporter) {
+		e.timeout = timeout
+	}
+}
+
+func init() {
+	lumigotracer.RegisterSpansExporter(lumigotracer.OTLPSpansExporter, newSpansExporter)
+}
+
+// spansExporter the Exporter of the OTLPSpansExporter, created on the
+// first invocation sending to it and kept for the next ones
+var spansExporter struct {
+	once     sync.Once
+	exporter *Exporter
+	err      error
+}
+
+// newSpansExporter returns the Exporter of the OTLPSpansExporter,
+// configured by the LUMIGO_OTLP_* environment variables alone
+func newSpansExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	spansExporter.once.Do(func() {
+		// the exporter outlives the invocation of ctx
+		spansExporter.exporter, spansExporter.err = New(context.Background())
+	})
+	if spansExporter.err != nil {
+		return nil, spansExporter.err
+	}
+	return spansExporter.exporter, nil
+}
+
+// New creates an Exporter and starts its client, the options take
+// precedence over the LUMIGO_OTLP_ENDPOINT and LUMIGO_OTLP_PROTOCOL
+// environment variables
+func New(ctx context.Context, opts ...Option) (*Exporter, error) {
+	e := &Exporter{
+		endpoint: os.Getenv("LUMIGO_OTLP_ENDPOINT"),
+		protocol: HTTPProtocol,
+		timeout:  defaultTimeout,
+	}
+	if protocol := os.Getenv("LUMIGO_OTLP_PROTOCOL"); protocol != "" {
+		e.protocol = protocol
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	var endpointURL *url.URL
+	if e.endpoint != "" {
+		var err error
+		endpointURL, err = url.Parse(e.endpoint)
+		if err != nil || endpointURL.Host == "" {
+			return nil, errors.Errorf("invalid OTLP endpoint: %s", e.endpoint)
+		}
+	}
+
+	var client otlptrace.Client
+	switch e.protocol {
+	case GRPCProtocol:
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithCompressor("gzip")}
+		if endpointURL != nil {
+			opts = append(opts, otlptracegrpc.WithEndpoint(endpointURL.Host))
+			if endpointURL.Scheme == "http" {
+				opts = append(opts, otlptracegrpc.WithInsecure())
+			}
+		}
+		client = otlptracegrpc.NewClient(opts...)
+	case HTTPProtocol:
+		opts := []otlptracehttp.Option{otlptracehttp.WithCompression(otlptracehttp.GzipCompression)}
+		if endpointURL != nil {
+			opts = append(opts, otlptracehttp.WithEndpoint(endpointURL.Host))
+			if endpointURL.Scheme == "http" {
+				opts = append(opts, otlptracehttp.WithInsecure())
+			}
+			if endpointURL.Path != "" && endpointURL.Path != "/" {
+				opts = append(opts, otlptracehttp.WithURLPath(endpointURL.Path))
+			}
+		}
+		client = otlptracehttp.NewClient(opts...)
+	default:
+		return nil, errors.Errorf("invalid OTLP protocol: %s", e.protocol)
+	}
+
+	exporter, err := otlptrace.New(ctx, client)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to start OTLP exporter")
+	}
+	e.exporter = exporter
+	return e, nil
+}
+
+// ExportSpans sends the spans mapped onto the semantic conventions
+func (e *Exporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if e == nil || len(spans) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	semconvSpans := make([]sdktrace.ReadOnlySpan, 0, len(spans))
+	for _, span := range spans {
+		semconvSpans = append(semconvSpans, toSemconvSpan(span))
+	}
+	return errors.Wrap(e.exporter.ExportSpans(ctx, semconvSpans), "failed to send spans to the collector")
+}
+
+// Shutdown flushes and stops the exporter, the tracer does not stop
+// the exporters it is given
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	return e.exporter.Shutdown(ctx)
+}
+
+// semconvSpan a span with the Lumigo attributes mapped onto the
+// semantic conventions
+type semconvSpan struct {
+	sdktrace.ReadOnlySpan
+	attributes []attribute.KeyValue
+	events     []sdktrace.Event
+	status     sdktrace.Status
+	resource   *resource.Resource
+}
+
+func (s semconvSpan) Attributes() []attribute.KeyValue { return s.attributes }
+func (s semconvSpan) Events() []sdktrace.Event         { return s.events }
+func (s semconvSpan) Status() sdktrace.Status          { return s.status }
+func (s semconvSpan) Resource() *resource.Resource     { return s.resource }
+
+// toSemconvSpan maps the Lumigo attributes of a span onto the semantic
+// conventions, the errors of the function span become an exception
+// event, and drops the token and the event of the resource
+func toSemconvSpan(span sdktrace.ReadOnlySpan) sdktrace.ReadOnlySpan {
+	mapped := semconvSpan{
+		ReadOnlySpan: span,
+		attributes:   make([]attribute.KeyValue, 0, len(span.Attributes())),
+		events:       span.Events(),
+		status:       span.Status(),
+		resource:     span.Resource(),
+	}
+
+	var hasError bool
+	var exception []attribute.KeyValue
+	for _, kv := range span.Attributes() {
+		switch kv.Key {
+		case "has_error":
+			hasError = kv.Value.AsBool()
+		case "error_type":
+			exception = append(exception, semconv.ExceptionTypeKey.String(kv.Value.AsString()))
+		case "error_message":
+			exception = append(exception, semconv.ExceptionMessageKey.String(kv.Value.AsString()))
+		case "error_stacktrace":
+			exception = append(exception, semconv.ExceptionStacktraceKey.String(kv.Value.AsString()))
+		default:
+			if key, ok := lumigoAttributes[kv.Key]; ok {
+				mapped.attributes = append(mapped.attributes, attribute.KeyValue{Key: key, Value: kv.Value})
+			} else if prefix, ok := lumigoHeadersAttributes[kv.Key]; ok {
+				mapped.attributes = append(mapped.attributes, headersAttributes(prefix, kv.Value.AsString())...)
+			} else {
+				mapped.attributes = append(mapped.attributes, kv)
+			}
+		}
+	}
+
+	if hasError || len(exception) > 0 {
+		mapped.events = append(append([]sdktrace.Event{}, span.Events()...), sdktrace.Event{
+			Name:       semconv.ExceptionEventName,
+			Attributes: exception,
+			Time:       span.EndTime(),
+		})
+		if mapped.status.Code != codes.Error {
+			mapped.status = sdktrace.Status{Code: codes.Error}
+			for _, kv := range exception {
+				if kv.Key == semconv.ExceptionMessageKey {
+					mapped.status.Description = kv.Value.AsString()
+				}
+			}
+		}
+	}
+
+	if res := span.Resource(); res != nil {
+		filtered, _ := res.Set().Filter(func(kv attribute.KeyValue) bool {
+			return kv.Key != "lumigo_token" && kv.Key != "event"
+		})
+		mapped.resource = resource.NewWithAttributes(res.SchemaURL(), filtered.ToSlice()...)
+	}
+	return mapped
+}
+
+// headersAttributes returns an attribute per header of the json headers,
+// named after the lowercase header with prefix
+func headersAttributes(prefix, headersJSON string) []attribute.KeyValue {
+	var headers map[string]interface{}
+	if err := json.Unmarshal([]byte(headersJSON), &headers); err != nil {
+		return nil
+	}
+	attrs := make([]attribute.KeyValue, 0, len(headers))
+	for name, value := range headers {
+		var values []string
+		switch value := value.(type) {
+		case string:
+			values = []string{value}
+		case []interface{}:
+			// Set-Cookie keeps its values as a list
+			for _, v := range value {
+				values = append(values, fmt.Sprint(v))
+			}
+		default:
+			continue
+		}
+		attrs = append(attrs, attribute.StringSlice(prefix+strings.ToLower(name), values))
+	}
+	return attrs
+}
@@ -0,0 +1,151 @@
+package otlp
+
+import (
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lumigo-io/go-tracer-beta/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func testFunctionSpanStub() *tracetest.SpanStub {
+	spanID, _ := oteltrace.SpanIDFromHex("83887e5d7da921ba")
+	traceID, _ := oteltrace.TraceIDFromHex("83887e5d7da921ba83887e5d7da921ba")
+	return &tracetest.SpanStub{
+		Name:      "LumigoParentSpan",
+		StartTime: time.Now(),
+		EndTime:   time.Now(),
+		SpanContext: oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+			SpanID:  spanID,
+			TraceID: traceID,
+		}),
+		Attributes: []attribute.KeyValue{
+			attribute.String("event", `{"key":"value"}`),
+			attribute.String("response", `"ok"`),
+			attribute.String("faas.execution", "123"),
+			attribute.String("http.request_headers", `{"Content-Type":"application/json"}`),
+			attribute.String("http.response_headers", `{"Set-Cookie":["a=1; Expires=Wed, 21 Oct 2026 07:28:00 GMT","b=2"]}`),
+			attribute.Bool("has_error", true),
+			attribute.String("error_type", "*errors.errorString"),
+			attribute.String("error_message", "failed"),
+			attribute.String("error_stacktrace", "main.go:10"),
+		},
+		Resource: resource.NewWithAttributes(semconv.SchemaURL,
+			attribute.String("lumigo_token", "t_123"),
+			attribute.String("event", `{"key":"value"}`),
+			semconv.CloudRegionKey.String("us-east-1"),
+		),
+	}
+}
+
+func TestToSemconvSpan(t *testing.T) {
+	span := toSemconvSpan(testFunctionSpanStub().Snapshot())
+
+	attrs := testutil.AttributesMap(span.Attributes())
+	assert.Equal(t, `{"key":"value"}`, attrs["lumigo.event"].AsString())
+	assert.Equal(t, `"ok"`, attrs["lumigo.response"].AsString())
+	assert.Equal(t, "123", attrs["faas.execution"].AsString())
+	assert.Equal(t, []string{"application/json"}, attrs["http.request.header.content-type"].AsStringSlice())
+	assert.Equal(t, []string{"a=1; Expires=Wed, 21 Oct 2026 07:28:00 GMT", "b=2"}, attrs["http.response.header.set-cookie"].AsStringSlice())
+	for _, key := range []attribute.Key{"event", "response", "http.request_headers", "has_error", "error_message"} {
+		assert.NotContains(t, attrs, key)
+	}
+
+	assert.Equal(t, codes.Error, span.Status().Code)
+	assert.Equal(t, "failed", span.Status().Description)
+	assert.Len(t, span.Events(), 1)
+	exception := span.Events()[0]
+	assert.Equal(t, semconv.ExceptionEventName, exception.Name)
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		semconv.ExceptionTypeKey.String("*errors.errorString"),
+		semconv.ExceptionMessageKey.String("failed"),
+		semconv.ExceptionStacktraceKey.String("main.go:10"),
+	}, exception.Attributes)
+
+	resourceAttrs := testutil.AttributesMap(span.Resource().Attributes())
+	assert.NotContains(t, resourceAttrs, attribute.Key("lumigo_token"))
+	assert.NotContains(t, resourceAttrs, attribute.Key("event"))
+	assert.Equal(t, "us-east-1", resourceAttrs[semconv.CloudRegionKey].AsString())
+}
+
+func TestExporterHTTP(t *testing.T) {
+	var received collectortrace.ExportTraceServiceRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/custom/traces", r.URL.Path)
+		assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+		body, err := gzip.NewReader(r.Body)
+		assert.NoError(t, err)
+		data, err := ioutil.ReadAll(body)
+		assert.NoError(t, err)
+		assert.NoError(t, proto.Unmarshal(data, &received))
+	}))
+	defer ts.Close()
+
+	exp, err := New(context.Background(), WithEndpoint(ts.URL+"/custom/traces"))
+	assert.NoError(t, err)
+	assert.NoError(t, exp.ExportSpans(context.Background(), tracetest.SpanStubs{*testFunctionSpanStub()}.Snapshots()))
+	assert.NoError(t, exp.Shutdown(context.Background()))
+
+	assert.Len(t, received.ResourceSpans, 1)
+	for _, kv := range received.ResourceSpans[0].Resource.Attributes {
+		assert.NotEqual(t, "lumigo_token", kv.Key)
+	}
+	spans := received.ResourceSpans[0].InstrumentationLibrarySpans[0].Spans
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "LumigoParentSpan", spans[0].Name)
+	keys := []string{}
+	for _, kv := range spans[0].Attributes {
+		keys = append(keys, kv.Key)
+	}
+	assert.Contains(t, keys, "lumigo.event")
+	assert.Equal(t, "exception", spans[0].Events[0].Name)
+}
+
+func TestNewInvalid(t *testing.T) {
+	_, err := New(context.Background(), WithEndpoint("collector:4318"), WithProtocol(GRPCProtocol))
+	assert.Error(t, err)
+
+	_, err = New(context.Background(), WithProtocol("thrift"))
+	assert.Error(t, err)
+
+	t.Setenv("LUMIGO_OTLP_PROTOCOL", "thrift")
+	_, err = New(context.Background())
+	assert.Error(t, err)
+
+	// the options take precedence over the environment
+	exp, err := New(context.Background(), WithProtocol(GRPCProtocol))
+	assert.NoError(t, err)
+	assert.NoError(t, exp.Shutdown(context.Background()))
+}
+
+func TestSpansExporter(t *testing.T) {
+	received := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+	}))
+	defer ts.Close()
+	t.Setenv("LUMIGO_OTLP_ENDPOINT", ts.URL+"/v1/traces")
+
+	// the exporter of LUMIGO_SPANS_EXPORTERS is created once from the environment
+	exporter, err := newSpansExporter(context.Background())
+	assert.NoError(t, err)
+	again, err := newSpansExporter(context.Background())
+	assert.NoError(t, err)
+	assert.Same(t, exporter, again)
+
+	assert.NoError(t, exporter.ExportSpans(context.Background(), tracetest.SpanStubs{*testFunctionSpanStub()}.Snapshots()))
+	assert.Equal(t, 1, received)
+}
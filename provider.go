@@ -0,0 +1,49 @@
+package lumigotracer
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// resourceExporter exports the spans of a TracerProvider of the user
+// with the Lumigo resource merged into theirs, which the Lumigo
+// exporters need to map the spans
+type resourceExporter struct {
+	exporter sdktrace.SpanExporter
+	resource *resource.Resource
+}
+
+// newResourceExporter creates a resourceExporter merging res into
+// the resource of the spans
+func newResourceExporter(exporter sdktrace.SpanExporter, res *resource.Resource) *resourceExporter {
+	return &resourceExporter{exporter: exporter, resource: res}
+}
+
+// ExportSpans exports the spans with the Lumigo resource
+func (e *resourceExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	merged := make([]sdktrace.ReadOnlySpan, 0, len(spans))
+	for _, span := range spans {
+		res, err := resource.Merge(span.Resource(), e.resource)
+		if err != nil {
+			// the schema URLs differ, the Lumigo resource is kept
+			res = e.resource
+		}
+		merged = append(merged, resourceSpan{ReadOnlySpan: span, resource: res})
+	}
+	return e.exporter.ExportSpans(ctx, merged)
+}
+
+// Shutdown stops the exporter
+func (e *resourceExporter) Shutdown(ctx context.Context) error {
+	return e.exporter.Shutdown(ctx)
+}
+
+// resourceSpan a span with another resource
+type resourceSpan struct {
+	sdktrace.ReadOnlySpan
+	resource *resource.Resource
+}
+
+func (s resourceSpan) Resource() *resource.Resource { return s.resource }
@@ -0,0 +1,116 @@
+package lumigotracer
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/lumigo-io/go-tracer-beta/internal/telemetry"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func readSpansFromDir(t *testing.T, dir string) []telemetry.Span {
+	files, err := spanFiles(dir)
+	assert.NoError(t, err)
+	var spans []telemetry.Span
+	for _, file := range files {
+		var fileSpans []telemetry.Span
+		content, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(content, &fileSpans))
+		spans = append(spans, fileSpans...)
+	}
+	return spans
+}
+
+func TestWrapHandlerTracerProvider(t *testing.T) {
+	logger.Out = ioutil.Discard
+	t.Setenv("AWS_LAMBDA_FUNCTION_NAME", "testFunction")
+	t.Setenv("AWS_REGION", "us-east-1")
+
+	previousProvider := otel.GetTracerProvider()
+	globalProvider := trace.NewNoopTracerProvider()
+	otel.SetTracerProvider(globalProvider)
+	defer otel.SetTracerProvider(previousProvider)
+
+	sr := tracetest.NewSpanRecorder()
+	userProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(sr),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "orders"))),
+	)
+
+	dir := t.TempDir()
+	handler := WrapHandler(func(ctx context.Context) (string, error) {
+		_, span := userProvider.Tracer("orders").Start(ctx, "process")
+		span.End()
+		return "ok", nil
+	}, &Config{Token: "token", TracerProvider: userProvider, SpansDir: dir}).(func(context.Context, json.RawMessage) (interface{}, error))
+
+	ctx := lambdacontext.NewContext(context.Background(), &mockLambdaContext)
+	for i := 0; i < 2; i++ {
+		_, err := handler(ctx, json.RawMessage(`{}`))
+		assert.NoError(t, err)
+	}
+
+	// the globals are left as they are
+	assert.Equal(t, globalProvider, otel.GetTracerProvider())
+
+	// the processors of the user still get the spans
+	names := map[string]int{}
+	for _, span := range sr.Ended() {
+		names[span.Name()]++
+	}
+	assert.Equal(t, 2, names["process"])
+	assert.Equal(t, 2, names["LumigoParentSpan"])
+
+	// the Lumigo exporter of each invocation is removed at its end,
+	// so the spans are not written twice
+	spans := readSpansFromDir(t, dir)
+	var functionSpans int
+	for _, span := range spans {
+		assert.Equal(t, "token", span.Token)
+		assert.Equal(t, "us-east-1", span.Region)
+		if span.ID == mockLambdaContext.AwsRequestID {
+			functionSpans++
+		}
+	}
+	assert.Equal(t, 2, functionSpans)
+}
+
+func TestWrapHandlerTracerProviderPanic(t *testing.T) {
+	logger.Out = ioutil.Discard
+	t.Setenv("AWS_LAMBDA_FUNCTION_NAME", "testFunction")
+	t.Setenv("AWS_REGION", "us-east-1")
+
+	exporter := tracetest.NewInMemoryExporter()
+	userProvider := sdktrace.NewTracerProvider()
+	handler := WrapHandler(func(ctx context.Context) (string, error) {
+		panic("nil map")
+	}, &Config{
+		Token:          "token",
+		TracerProvider: userProvider,
+		SpansDir:       t.TempDir(),
+		Exporters:      []sdktrace.SpanExporter{exporter},
+	}).(func(context.Context, json.RawMessage) (interface{}, error))
+
+	ctx := lambdacontext.NewContext(context.Background(), &mockLambdaContext)
+	assert.Panics(t, func() {
+		handler(ctx, json.RawMessage(`{}`)) // nolint
+	})
+
+	// the Lumigo processor of the invocation is removed despite the panic
+	exporter.Reset()
+	_, span := userProvider.Tracer("orders").Start(context.Background(), "after")
+	span.End()
+	assert.NoError(t, userProvider.ForceFlush(context.Background()))
+	assert.Empty(t, exporter.GetSpans())
+}
@@ -0,0 +1,104 @@
+package lumigotracer
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// connTiming tracks the connection phases of an outgoing
+// HTTP request through httptrace
+type connTiming struct {
+	mu sync.Mutex
+
+	start             time.Time
+	dnsStart          time.Time
+	dnsDone           time.Time
+	connectStart      time.Time
+	connectDone       time.Time
+	tlsStart          time.Time
+	tlsDone           time.Time
+	firstResponseByte time.Time
+	reused            bool
+}
+
+func newConnTiming() *connTiming {
+	return &connTiming{start: time.Now()}
+}
+
+// withClientTrace returns a context which records the
+// connection phases in the connTiming
+func (ct *connTiming) withClientTrace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			ct.mu.Lock()
+			defer ct.mu.Unlock()
+			ct.reused = info.Reused
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			ct.record(&ct.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			ct.record(&ct.dnsDone)
+		},
+		ConnectStart: func(string, string) {
+			ct.record(&ct.connectStart)
+		},
+		ConnectDone: func(string, string, error) {
+			ct.record(&ct.connectDone)
+		},
+		TLSHandshakeStart: func() {
+			ct.record(&ct.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			ct.record(&ct.tlsDone)
+		},
+		GotFirstResponseByte: func() {
+			ct.record(&ct.firstResponseByte)
+		},
+	})
+}
+
+// record sets the phase time once, since dialing in parallel
+// may report a phase more than once
+func (ct *connTiming) record(phase *time.Time) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	if phase.IsZero() {
+		*phase = time.Now()
+	}
+}
+
+// attributes returns the phases durations in milliseconds,
+// skipping the phases which did not happen
+func (ct *connTiming) attributes() []attribute.KeyValue {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	attrs := []attribute.KeyValue{
+		attribute.Bool("http.timing.connection_reused", ct.reused),
+	}
+	if d, ok := phaseDuration(ct.dnsStart, ct.dnsDone); ok {
+		attrs = append(attrs, attribute.Float64("http.timing.dns", d))
+	}
+	if d, ok := phaseDuration(ct.connectStart, ct.connectDone); ok {
+		attrs = append(attrs, attribute.Float64("http.timing.connect", d))
+	}
+	if d, ok := phaseDuration(ct.tlsStart, ct.tlsDone); ok {
+		attrs = append(attrs, attribute.Float64("http.timing.tls", d))
+	}
+	if d, ok := phaseDuration(ct.start, ct.firstResponseByte); ok {
+		attrs = append(attrs, attribute.Float64("http.timing.ttfb", d))
+	}
+	return attrs
+}
+
+func phaseDuration(start, end time.Time) (float64, bool) {
+	if start.IsZero() || end.IsZero() {
+		return 0, false
+	}
+	return float64(end.Sub(start)) / float64(time.Millisecond), true
+}
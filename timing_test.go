@@ -0,0 +1,56 @@
+package lumigotracer
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lumigo-io/go-tracer-beta/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTransportConnTiming(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := w.Write([]byte("Hello, world!")); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer ts.Close()
+
+	sr := tracetest.NewSpanRecorder()
+	tr := NewTransport(ts.Client().Transport)
+	tr.provider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	c := http.Client{Transport: tr}
+
+	for i := 0; i < 2; i++ {
+		res, err := c.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ioutil.ReadAll(res.Body); err != nil {
+			t.Fatal(err)
+		}
+		assert.NoError(t, res.Body.Close())
+	}
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 2)
+
+	first := testutil.AttributesMap(spans[0].Attributes())
+	assert.False(t, first["http.timing.connection_reused"].AsBool())
+	assert.Contains(t, first, attribute.Key("http.timing.connect"))
+	assert.Contains(t, first, attribute.Key("http.timing.tls"))
+	assert.Contains(t, first, attribute.Key("http.timing.ttfb"))
+	// the test server listens on an IP, there is no DNS lookup
+	assert.NotContains(t, first, attribute.Key("http.timing.dns"))
+
+	second := testutil.AttributesMap(spans[1].Attributes())
+	assert.True(t, second["http.timing.connection_reused"].AsBool())
+	assert.NotContains(t, second, attribute.Key("http.timing.connect"))
+	assert.NotContains(t, second, attribute.Key("http.timing.tls"))
+	assert.Contains(t, second, attribute.Key("http.timing.ttfb"))
+}
@@ -5,23 +5,31 @@ import (
 	"encoding/json"
 	"os"
 	"reflect"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/propagation"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/trace"
 )
 
+// defaultFlushTimeout the most time the export of the last spans of an
+// invocation takes when it has no deadline
+const defaultFlushTimeout = 5 * time.Second
+
 type tracer struct {
-	provider  *sdktrace.TracerProvider
-	logger    logrus.FieldLogger
-	span      trace.Span
-	eventData []byte
-	ctx       context.Context
-	traceCtx  context.Context
+	provider      *sdktrace.TracerProvider
+	processor     sdktrace.SpanProcessor
+	unregister    sync.Once
+	logger        logrus.FieldLogger
+	span          trace.Span
+	eventData     []byte
+	ctx           context.Context
+	traceCtx      context.Context
+	propagateXRay bool
 }
 
 func NewTracer(ctx context.Context, cfg Config, payload json.RawMessage) (retTracer *tracer, err error) {
@@ -31,7 +39,7 @@ func NewTracer(ctx context.Context, cfg Config, payload json.RawMessage) (retTra
 		logger: logger,
 	}
 
-	exporter, err := createExporter(cfg.PrintStdout, ctx, logger)
+	exporters, err := createExporters(cfg, ctx, logger)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create otel exporter")
 	}
@@ -42,15 +50,38 @@ func NewTracer(ctx context.Context, cfg Config, payload json.RawMessage) (retTra
 	}
 	retTracer.eventData = data
 
-	tracerProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithSyncer(exporter),
-		sdktrace.WithResource(newResource(ctx,
-			attribute.String("event", string(retTracer.eventData)),
-		)),
-	)
+	res := newResource(ctx, attribute.String("event", string(retTracer.eventData)))
+	retTracer.propagateXRay = cfg.PropagateXRay
+
+	// the provider of the user gets the Lumigo exporters for this
+	// invocation, its processors and the globals are left as they are
+	if cfg.TracerProvider != nil {
+		resourceExporters := make([]sdktrace.SpanExporter, 0, len(exporters))
+		for _, exporter := range exporters {
+			if batched, ok := exporter.(batchedExporter); ok {
+				resourceExporters = append(resourceExporters, batchedExporter{newResourceExporter(batched.SpanExporter, res)})
+				continue
+			}
+			resourceExporters = append(resourceExporters, newResourceExporter(exporter, res))
+		}
+		processor := newFanOutProcessor(logger, resourceExporters...)
+		cfg.TracerProvider.RegisterSpanProcessor(processor)
+		retTracer.provider = cfg.TracerProvider
+		retTracer.processor = processor
+		return retTracer, nil
+	}
+
+	providerOptions := []sdktrace.TracerProviderOption{
+		sdktrace.WithSpanProcessor(newFanOutProcessor(logger, exporters...)),
+		sdktrace.WithResource(res),
+	}
+	if idGenerator := newIDGenerator(cfg.PropagateXRay); idGenerator != nil {
+		providerOptions = append(providerOptions, sdktrace.WithIDGenerator(idGenerator))
+	}
+	tracerProvider := sdktrace.NewTracerProvider(providerOptions...)
 	retTracer.provider = tracerProvider
 	otel.SetTracerProvider(tracerProvider)
-	otel.SetTextMapPropagator(propagation.TraceContext{})
+	otel.SetTextMapPropagator(newTextMapPropagator(cfg.PropagateXRay))
 
 	return retTracer, nil
 }
@@ -62,7 +93,16 @@ func (t *tracer) Start() {
 	t.logger.Info("tracer starting")
 	os.Setenv("IS_WARM_START", "true") // nolint
 
-	traceCtx, span := t.provider.Tracer("lumigo").Start(t.ctx, "LumigoParentSpan")
+	// continue the trace of the caller or of the producer of the triggering messages
+	parentCtx := extractInvocationContext(t.ctx)
+	if !trace.SpanContextFromContext(parentCtx).IsRemote() {
+		parentCtx = extractMessagingContext(t.ctx, t.eventData)
+	}
+	// otherwise join the X-Ray trace of the invocation
+	if t.propagateXRay && !trace.SpanContextFromContext(parentCtx).IsRemote() {
+		parentCtx = extractAmazonTraceContext(t.ctx)
+	}
+	traceCtx, span := t.provider.Tracer("lumigo").Start(parentCtx, "LumigoParentSpan")
 	span.SetAttributes(attribute.String("event", string(t.eventData)))
 	t.span = span
 	t.traceCtx = traceCtx
@@ -83,8 +123,46 @@ func (t *tracer) End(response []byte, lambdaErr error) {
 		t.span.SetAttributes(attribute.String("error_message", lambdaErr.Error()))
 		t.span.SetAttributes(attribute.String("error_stacktrace", takeStacktrace()))
 	}
-	t.provider.ForceFlush(t.traceCtx)
 	t.span.End()
 
+	// the batched exporters get the last spans when the processor of the
+	// invocation is stopped, before the invocation deadline
+	ctx, cancel, ok := flushContext(t.ctx)
+	defer cancel()
+	if !ok {
+		t.logger.Warn("no invocation time left to flush the spans, the spans not sent to the batched exporters yet are dropped")
+	}
+	if t.processor != nil {
+		if err := t.processor.Shutdown(ctx); err != nil {
+			t.logger.WithError(err).Error("failed to flush spans")
+		}
+		t.unregisterProcessor()
+	} else if err := t.provider.Shutdown(ctx); err != nil {
+		t.logger.WithError(err).Error("failed to flush spans")
+	}
+
 	t.logger.Info("tracer ending")
 }
+
+// unregisterProcessor removes the processor of the invocation from the
+// provider of the user, once, whether the handler returned or panicked
+func (t *tracer) unregisterProcessor() {
+	if t.processor == nil {
+		return
+	}
+	t.unregister.Do(func() {
+		t.provider.UnregisterSpanProcessor(t.processor)
+	})
+}
+
+// flushContext returns the context of the export of the last spans of
+// an invocation, which ends before the invocation deadline of ctx. It
+// returns false if there is no time left, the context is done then
+func flushContext(ctx context.Context) (context.Context, context.CancelFunc, bool) {
+	timeout, ok := exportTimeout(ctx, defaultFlushTimeout)
+	if !ok {
+		timeout = 0
+	}
+	flushCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	return flushCtx, cancel, ok
+}
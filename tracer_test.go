@@ -0,0 +1,90 @@
+package lumigotracer
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestTracerExportsBatches(t *testing.T) {
+	logger.Out = ioutil.Discard
+	previousProvider := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(previousProvider)
+
+	var mu sync.Mutex
+	var batches [][]string
+	exporter := funcExporter{export: func(spans []sdktrace.ReadOnlySpan) error {
+		mu.Lock()
+		defer mu.Unlock()
+		var names []string
+		for _, span := range spans {
+			names = append(names, span.Name())
+		}
+		batches = append(batches, names)
+		return nil
+	}}
+
+	ctx := lambdacontext.NewContext(context.Background(), &mockLambdaContext)
+	tracer, err := NewTracer(ctx, Config{Token: "token", SpansDir: t.TempDir(), Exporters: []sdktrace.SpanExporter{exporter}}, json.RawMessage(`{}`))
+	assert.NoError(t, err)
+	tracer.Start()
+	for _, name := range []string{"first", "second"} {
+		_, span := tracer.provider.Tracer("test").Start(tracer.traceCtx, name)
+		span.End()
+	}
+
+	// the spans are not exported as they end
+	mu.Lock()
+	assert.Empty(t, batches)
+	mu.Unlock()
+
+	// but in a batch at the end of the invocation
+	tracer.End([]byte(`"ok"`), nil)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, [][]string{{"first", "second", "LumigoParentSpan"}}, batches)
+}
+
+func TestTracerWritesSpanFilesAsTheSpansEnd(t *testing.T) {
+	logger.Out = ioutil.Discard
+	previousProvider := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(previousProvider)
+
+	dir := t.TempDir()
+	ctx := lambdacontext.NewContext(context.Background(), &mockLambdaContext)
+	tracer, err := NewTracer(ctx, Config{Token: "token", SpansDir: dir}, json.RawMessage(`{}`))
+	assert.NoError(t, err)
+	tracer.Start()
+	_, span := tracer.provider.Tracer("test").Start(tracer.traceCtx, "HttpSpan")
+	span.End()
+
+	// the extension reads the span files before the invocation ends
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, files)
+	tracer.End([]byte(`"ok"`), nil)
+}
+
+func TestFlushContext(t *testing.T) {
+	ctx, cancel, ok := flushContext(context.Background())
+	defer cancel()
+	assert.True(t, ok)
+	deadline, _ := ctx.Deadline()
+	assert.WithinDuration(t, time.Now().Add(defaultFlushTimeout), deadline, time.Second)
+
+	invocationCtx, invocationCancel := context.WithDeadline(context.Background(), time.Now().Add(exportDeadlineMargin/2))
+	defer invocationCancel()
+	ctx, cancel, ok = flushContext(invocationCtx)
+	defer cancel()
+	assert.False(t, ok)
+	assert.Error(t, ctx.Err())
+}
@@ -3,11 +3,13 @@ package lumigotracer
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"io"
-	"io/ioutil"
 	"net/http"
+	"regexp"
+	"sync"
 
+	"github.com/lumigo-io/go-tracer-beta/internal/awsparser"
+	"github.com/lumigo-io/go-tracer-beta/internal/telemetry"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -16,97 +18,320 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
+// defaultMaxBodySize the maximum number of bytes captured
+// from a request or response body
+const defaultMaxBodySize = 2048
+
+// defaultHttpSpanName the name of the spans created by Transport
+const defaultHttpSpanName = "HttpSpan"
+
 type Transport struct {
-	rt         http.RoundTripper
-	provider   trace.TracerProvider
-	propagator propagation.TextMapPropagator
+	rt http.RoundTripper
+	// provider and propagator are the global ones if nil, resolved
+	// on every request since a transport may be created before
+	// the tracer is set up for the invocation
+	provider    trace.TracerProvider
+	propagator  propagation.TextMapPropagator
+	maxBodySize int
+	propagate   bool
+	spanName    func(*http.Request) string
+
+	// headerAllowlist overrides the global allowlist if not nil
+	headerAllowlist map[string]bool
+
+	ignoredDomains []*regexp.Regexp
+	ignoredPaths   []*regexp.Regexp
+}
+
+// TransportOption configures a Transport
+type TransportOption func(*Transport)
+
+// WithoutBodies disables the capture of request and response bodies
+func WithoutBodies() TransportOption {
+	return func(t *Transport) {
+		t.maxBodySize = 0
+	}
+}
+
+// WithMaxBodySize limits the bytes captured from request and
+// response bodies, a size of zero disables the capture
+func WithMaxBodySize(size int) TransportOption {
+	return func(t *Transport) {
+		t.maxBodySize = size
+	}
+}
+
+// WithHeaderAllowlist captures only these HTTP headers, overriding
+// the allowlist of the global config for this transport
+func WithHeaderAllowlist(headers ...string) TransportOption {
+	return func(t *Transport) {
+		t.headerAllowlist = canonicalHeaderSet(headers)
+	}
+}
+
+// WithSpanName names the spans after the request
+func WithSpanName(spanName func(*http.Request) string) TransportOption {
+	return func(t *Transport) {
+		t.spanName = spanName
+	}
+}
+
+// WithPropagation switches the injection of the trace context
+// headers into the outgoing requests, enabled by default
+func WithPropagation(propagate bool) TransportOption {
+	return func(t *Transport) {
+		t.propagate = propagate
+	}
+}
+
+// WithPropagator injects the trace context with the given propagator
+// instead of the global one, e.g. to send X-Amzn-Trace-Id to a single service
+func WithPropagator(propagator propagation.TextMapPropagator) TransportOption {
+	return func(t *Transport) {
+		t.propagator = propagator
+	}
+}
+
+// WithIgnoredDomains skips tracing of calls to hosts matching any
+// of the patterns, on top of the ones in the global config
+func WithIgnoredDomains(patterns ...string) TransportOption {
+	return func(t *Transport) {
+		t.ignoredDomains = append(t.ignoredDomains, compileRegexList(patterns)...)
+	}
+}
+
+// WithIgnoredPaths skips tracing of calls to paths matching any
+// of the patterns, on top of the ones in the global config
+func WithIgnoredPaths(patterns ...string) TransportOption {
+	return func(t *Transport) {
+		t.ignoredPaths = append(t.ignoredPaths, compileRegexList(patterns)...)
+	}
+}
+
+// NewTransport returns a Transport tracing the calls of transport.
+// A transport that is already a Transport, like http.DefaultTransport
+// once instrumented, is unwrapped so that each call is traced once
+// with the given options
+func NewTransport(transport http.RoundTripper, opts ...TransportOption) *Transport {
+	if wrapped, ok := transport.(*Transport); ok {
+		transport = wrapped.rt
+	}
+	t := &Transport{
+		rt:          transport,
+		maxBodySize: defaultMaxBodySize,
+		propagate:   true,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// isIgnored checks both the global and the transport ignore lists
+func (t *Transport) isIgnored(req *http.Request) bool {
+	transportIgnorer := requestIgnorer{domains: t.ignoredDomains, paths: t.ignoredPaths}
+	return cfg.requestIgnorer().isIgnored(req) || transportIgnorer.isIgnored(req)
+}
+
+// headerFilter applies the transport allowlist on the global filter
+func (t *Transport) headerFilter() headerFilter {
+	filter := cfg.headerFilter()
+	if t.headerAllowlist != nil {
+		filter.allowlist = t.headerAllowlist
+	}
+	return filter
 }
 
-func NewTransport(transport http.RoundTripper) *Transport {
-	return &Transport{
-		rt:         transport,
-		provider:   otel.GetTracerProvider(),
-		propagator: otel.GetTextMapPropagator(),
+func (t *Transport) getSpanName(req *http.Request) string {
+	if t.spanName == nil {
+		return defaultHttpSpanName
 	}
+	if name := t.spanName(req); name != "" {
+		return name
+	}
+	return defaultHttpSpanName
 }
 
 func (t *Transport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
-	traceCtx, span := t.provider.Tracer("lumigo").Start(req.Context(), "HttpSpan")
+	if t.isIgnored(req) {
+		return t.rt.RoundTrip(req)
+	}
 
-	req = req.WithContext(traceCtx)
+	provider := t.provider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	traceCtx, span := provider.Tracer("lumigo").Start(req.Context(), t.getSpanName(req),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(telemetry.HttpSpanKey.Bool(true)))
+
+	timing := newConnTiming()
+	req = req.WithContext(timing.withClientTrace(traceCtx))
 	span.SetAttributes(semconv.HTTPClientAttributesFromHTTPRequest(req)...)
 	span.SetAttributes(semconv.HTTPTargetKey.String(req.URL.Path))
 	span.SetAttributes(semconv.HTTPHostKey.String(req.URL.Host))
-	t.propagator.Inject(traceCtx, propagation.HeaderCarrier(req.Header))
-
-	if req.Body != nil {
-		bodyBytes, bodyErr := io.ReadAll(req.Body)
-		if bodyErr != nil {
-			logger.WithError(bodyErr).Error("failed to parse request body")
+	if t.propagate {
+		propagator := t.propagator
+		if propagator == nil {
+			propagator = otel.GetTextMapPropagator()
 		}
-		span.SetAttributes(attribute.String("http.request_body", string(bodyBytes)))
-		// restore body
-		req.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
+		propagator.Inject(traceCtx, propagation.HeaderCarrier(req.Header))
 	}
 
-	reqHeaders := make(map[string]string)
-	for k, values := range req.Header {
-		for _, value := range values {
-			reqHeaders[k] = value
+	var reqBody *limitedBuffer
+	var awsReqBody *awsRequestCapture
+	isAWSRequest := awsparser.IsAWSRequest(req)
+	if req.Body != nil && req.Body != http.NoBody {
+		var captures []io.Writer
+		if t.maxBodySize > 0 {
+			reqBody = newLimitedBuffer(t.maxBodySize)
+			captures = append(captures, reqBody)
+		}
+		// AWS calls are parsed from a capture of their own so that
+		// they do not depend on the body capture options
+		if isAWSRequest && awsparser.ParsesRequestBody(req) {
+			awsReqBody = &awsRequestCapture{}
+			captures = append(captures, awsReqBody)
+		}
+		if len(captures) > 0 {
+			req.Body = &teeReadCloser{
+				Reader: io.TeeReader(req.Body, io.MultiWriter(captures...)),
+				Closer: req.Body,
+			}
 		}
 	}
-	headersJson, err := json.Marshal(reqHeaders)
-	if err != nil {
-		logger.WithError(err).Error("failed to fetch request headers")
-	}
-	span.SetAttributes(attribute.String("http.request_headers", string(headersJson)))
+
+	headers := t.headerFilter()
+	span.SetAttributes(attribute.String("http.request_headers", headers.format(req.Header)))
 
 	resp, err = t.rt.RoundTrip(req)
+	span.SetAttributes(timing.attributes()...)
+	if reqBody != nil {
+		span.SetAttributes(attribute.String("http.request_body", reqBody.format(req.Header)))
+	}
+	var awsInfo *awsparser.Info
+	if isAWSRequest {
+		info := parseAWSRequest(req, awsReqBody)
+		awsInfo = &info
+		span.SetAttributes(awsRequestAttributes(info)...)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.End()
+		return resp, err
+	}
 
 	// response
 	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(resp.StatusCode)...)
 	span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(resp.StatusCode))
 
-	responseHeaders := make(map[string]string)
-	for k, values := range resp.Header {
-		for _, value := range values {
-			responseHeaders[k] = value
+	span.SetAttributes(attribute.String("http.response_headers", headers.format(resp.Header)))
+
+	if resp.Body == nil {
+		if awsInfo != nil {
+			span.SetAttributes(awsResponseAttributes(*awsInfo, resp.Header, nil)...)
 		}
+		span.End()
+		return resp, err
 	}
-	headersJson, jsonErr := json.Marshal(responseHeaders)
-	if jsonErr != nil {
-		logger.WithError(err).Error("failed to fetch response headers")
+	wb := &wrappedBody{
+		ctx:     traceCtx,
+		span:    span,
+		body:    resp.Body,
+		header:  resp.Header,
+		awsInfo: awsInfo,
 	}
-	span.SetAttributes(attribute.String("http.response_headers", string(headersJson)))
+	if t.maxBodySize > 0 {
+		wb.capture = newLimitedBuffer(t.maxBodySize)
+	}
+	// the IDs of messaging calls are scanned as the body is read so that
+	// they do not depend on the body capture options
+	if awsInfo != nil {
+		wb.awsScanner = awsparser.NewMessageIDScanner(*awsInfo)
+	}
+	resp.Body = wb
+	return resp, err
+}
 
-	if resp.Body != nil {
-		bodyBytes, bodyErr := io.ReadAll(resp.Body)
-		if bodyErr != nil {
-			logger.WithError(bodyErr).Error("failed to parse response body")
+// limitedBuffer keeps the first limit bytes written to it
+// and silently discards the rest, while counting the total size
+type limitedBuffer struct {
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	limit int
+	size  int64
+}
+
+var _ io.Writer = &limitedBuffer{}
+
+func newLimitedBuffer(limit int) *limitedBuffer {
+	return &limitedBuffer{limit: limit}
+}
+
+func (lb *limitedBuffer) Write(p []byte) (int, error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	lb.size += int64(len(p))
+	if remaining := lb.limit - lb.buf.Len(); remaining > 0 {
+		if len(p) > remaining {
+			lb.buf.Write(p[:remaining])
+		} else {
+			lb.buf.Write(p)
 		}
-		span.SetAttributes(attribute.String("http.response_body", string(bodyBytes)))
-		resp.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
 	}
-	resp.Body = &wrappedBody{ctx: traceCtx, span: span, body: resp.Body}
-	return resp, err
+	return len(p), nil
+}
+
+// bytes returns a copy of the captured bytes
+func (lb *limitedBuffer) bytes() []byte {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return append([]byte{}, lb.buf.Bytes()...)
+}
+
+// format renders the captured bytes based on the body headers
+func (lb *limitedBuffer) format(header http.Header) string {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return formatBody(header, lb.buf.Bytes(), lb.size, lb.limit)
+}
+
+// teeReadCloser reads through a tee reader while
+// closing the original body
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
 }
 
 type wrappedBody struct {
-	ctx  context.Context
-	span trace.Span
-	body io.ReadCloser
+	ctx     context.Context
+	span    trace.Span
+	body    io.ReadCloser
+	capture *limitedBuffer
+	header  http.Header
+	awsInfo *awsparser.Info
+	// awsScanner finds the IDs in the body of AWS messaging calls
+	awsScanner *awsparser.MessageIDScanner
+	endOnce    sync.Once
 }
 
 var _ io.ReadCloser = &wrappedBody{}
 
 func (wb *wrappedBody) Read(b []byte) (int, error) {
 	n, err := wb.body.Read(b)
+	if wb.capture != nil && n > 0 {
+		_, _ = wb.capture.Write(b[:n])
+	}
+	if wb.awsScanner != nil && n > 0 {
+		_, _ = wb.awsScanner.Write(b[:n])
+	}
 
 	switch err {
 	case nil:
 		// nothing to do here but fall through to the return
 	case io.EOF:
-		wb.span.End()
+		wb.end()
 	default:
 		wb.span.RecordError(err)
 		wb.span.SetStatus(codes.Error, err.Error())
@@ -115,6 +340,20 @@ func (wb *wrappedBody) Read(b []byte) (int, error) {
 }
 
 func (wb *wrappedBody) Close() error {
-	wb.span.End()
+	wb.end()
 	return wb.body.Close()
 }
+
+// end finalizes the span with the captured body, only once
+// since the body can reach EOF and be closed afterwards
+func (wb *wrappedBody) end() {
+	wb.endOnce.Do(func() {
+		if wb.capture != nil {
+			wb.span.SetAttributes(attribute.String("http.response_body", wb.capture.format(wb.header)))
+		}
+		if wb.awsInfo != nil {
+			wb.span.SetAttributes(awsResponseAttributes(*wb.awsInfo, wb.header, wb.awsScanner)...)
+		}
+		wb.span.End()
+	})
+}
@@ -7,11 +7,18 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/lumigo-io/go-tracer-beta/internal/telemetry"
+	"github.com/lumigo-io/go-tracer-beta/internal/testutil"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -85,6 +92,7 @@ type span struct {
 
 	ended       bool
 	recordedErr error
+	attributes  []attribute.KeyValue
 
 	statusCode codes.Code
 	statusDesc string
@@ -94,6 +102,10 @@ func (s *span) End(...trace.SpanEndOption) {
 	s.ended = true
 }
 
+func (s *span) SetAttributes(kv ...attribute.KeyValue) {
+	s.attributes = append(s.attributes, kv...)
+}
+
 func (s *span) RecordError(err error, _ ...trace.EventOption) {
 	s.recordedErr = err
 }
@@ -139,3 +151,194 @@ func TestWrappedBodyCloseError(t *testing.T) {
 	wb := &wrappedBody{span: trace.Span(s), body: readCloser{closeErr: expectedErr}}
 	assert.Equal(t, expectedErr, wb.Close())
 }
+
+func TestWrappedBodyCaptureLimit(t *testing.T) {
+	s := new(span)
+	wb := &wrappedBody{
+		span:    trace.Span(s),
+		body:    ioutil.NopCloser(strings.NewReader("Hello, world!")),
+		capture: newLimitedBuffer(5),
+	}
+	body, err := ioutil.ReadAll(wb)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello, world!", string(body))
+	assert.True(t, s.ended)
+	assert.Contains(t, s.attributes, attribute.String("http.response_body", "Hello"))
+}
+
+func TestWrappedBodyEndOnce(t *testing.T) {
+	s := new(span)
+	wb := &wrappedBody{
+		span:    trace.Span(s),
+		body:    ioutil.NopCloser(strings.NewReader("Hello")),
+		capture: newLimitedBuffer(defaultMaxBodySize),
+	}
+	_, err := ioutil.ReadAll(wb)
+	assert.NoError(t, err)
+	assert.NoError(t, wb.Close())
+	assert.Len(t, s.attributes, 1)
+}
+
+func TestTransportStreamingCapture(t *testing.T) {
+	content := strings.Repeat("a", defaultMaxBodySize*2)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(body); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer ts.Close()
+
+	sr := tracetest.NewSpanRecorder()
+	tr := NewTransport(http.DefaultTransport)
+	tr.provider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	r, err := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := http.Client{Transport: tr}
+	res, err := c.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the span stays open until the body is consumed
+	assert.Len(t, sr.Ended(), 0)
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, content, string(body))
+	assert.NoError(t, res.Body.Close())
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 1)
+	attrs := spans[0].Attributes()
+	assert.Contains(t, attrs, attribute.String("http.request_body", content[:defaultMaxBodySize]))
+	assert.Contains(t, attrs, attribute.String("http.response_body", content[:defaultMaxBodySize]))
+}
+
+func TestTransportRoundTripError(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tr := NewTransport(http.DefaultTransport)
+	tr.provider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	r, err := http.NewRequest(http.MethodGet, "http://127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := http.Client{Transport: tr}
+	_, err = c.Do(r) // nolint
+	assert.Error(t, err)
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+}
+
+func TestTransportOptions(t *testing.T) {
+	var traceparent string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+		w.Header().Set("X-Response", "test")
+		if _, err := w.Write([]byte("Hello, world!")); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer ts.Close()
+
+	testcases := []struct {
+		testname string
+		opts     []TransportOption
+		check    func(t *testing.T, span sdktrace.ReadOnlySpan)
+	}{
+		{
+			testname: "defaults",
+			check: func(t *testing.T, span sdktrace.ReadOnlySpan) {
+				attrs := testutil.AttributesMap(span.Attributes())
+				assert.Equal(t, defaultHttpSpanName, span.Name())
+				assert.Equal(t, trace.SpanKindClient, span.SpanKind())
+				assert.Equal(t, "name=test", attrs["http.request_body"].AsString())
+				assert.Equal(t, "Hello, world!", attrs["http.response_body"].AsString())
+				assert.NotEmpty(t, traceparent)
+			},
+		},
+		{
+			testname: "without bodies",
+			opts:     []TransportOption{WithoutBodies()},
+			check: func(t *testing.T, span sdktrace.ReadOnlySpan) {
+				attrs := testutil.AttributesMap(span.Attributes())
+				assert.NotContains(t, attrs, attribute.Key("http.request_body"))
+				assert.NotContains(t, attrs, attribute.Key("http.response_body"))
+			},
+		},
+		{
+			testname: "max body size",
+			opts:     []TransportOption{WithMaxBodySize(4)},
+			check: func(t *testing.T, span sdktrace.ReadOnlySpan) {
+				attrs := testutil.AttributesMap(span.Attributes())
+				assert.Equal(t, "name", attrs["http.request_body"].AsString())
+				assert.Equal(t, "Hell", attrs["http.response_body"].AsString())
+			},
+		},
+		{
+			testname: "header allowlist",
+			opts:     []TransportOption{WithHeaderAllowlist("agent", "x-response")},
+			check: func(t *testing.T, span sdktrace.ReadOnlySpan) {
+				attrs := testutil.AttributesMap(span.Attributes())
+				assert.Equal(t, `{"Agent":"test"}`, attrs["http.request_headers"].AsString())
+				assert.Equal(t, `{"X-Response":"test"}`, attrs["http.response_headers"].AsString())
+			},
+		},
+		{
+			testname: "span name",
+			opts: []TransportOption{WithSpanName(func(req *http.Request) string {
+				return "payments " + req.Method
+			})},
+			check: func(t *testing.T, span sdktrace.ReadOnlySpan) {
+				assert.Equal(t, "payments POST", span.Name())
+				assert.True(t, telemetry.IsHttpSpan(span))
+			},
+		},
+		{
+			testname: "without propagation",
+			opts:     []TransportOption{WithPropagation(false)},
+			check: func(t *testing.T, span sdktrace.ReadOnlySpan) {
+				assert.Empty(t, traceparent)
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.testname, func(t *testing.T) {
+			traceparent = ""
+			sr := tracetest.NewSpanRecorder()
+			tr := NewTransport(http.DefaultTransport, tc.opts...)
+			tr.provider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+			tr.propagator = propagation.TraceContext{}
+
+			r, err := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader("name=test"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.Header.Set("Agent", "test")
+			c := http.Client{Transport: tr}
+			res, err := c.Do(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ioutil.ReadAll(res.Body); err != nil {
+				t.Fatal(err)
+			}
+			assert.NoError(t, res.Body.Close())
+
+			spans := sr.Ended()
+			assert.Len(t, spans, 1)
+			tc.check(t, spans[0])
+		})
+	}
+}
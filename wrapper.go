@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"io"
 	"os"
+	"sync"
 
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/lumigo-io/go-tracer-beta/internal/awsoperation"
 	lumigoctx "github.com/lumigo-io/go-tracer-beta/internal/context"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
@@ -36,6 +38,10 @@ func init() {
 		TimestampFormat: "2006-01-02 15:04:05",
 		LogFormat:       "#LUMIGO# - %time% - %lvl% - %msg%\n",
 	}
+	// the params of the AWS SDK operations are masked like the headers
+	awsoperation.Configure(logger, func(name string) bool {
+		return cfg.headerFilter().isSecret(name)
+	})
 }
 
 // WrapHandler wraps the lambda handler
@@ -47,9 +53,16 @@ func WrapHandler(handler interface{}, conf *Config) interface{} {
 	if !cfg.debug {
 		logger.Out = io.Discard
 	}
+	if cfg.InstrumentDefaultTransport {
+		InstrumentDefaultTransport()
+	}
+	if cfg.hasSpansExporter(ExtensionSpansExporter) {
+		removeStaleSpans(cfg.spansDir(), cfg.spansMaxAge(), logger)
+	}
 	return func(ctx context.Context, payload json.RawMessage) (interface{}, error) {
 		ctx = lumigoctx.NewContext(ctx, &lumigoctx.LumigoContext{
 			TracerVersion: version,
+			TransactionID: invocationTransactionID(ctx),
 		})
 		tracer, err := NewTracer(ctx, cfg, payload)
 		// catch all errors and exceptions
@@ -57,6 +70,9 @@ func WrapHandler(handler interface{}, conf *Config) interface{} {
 			response, err := lambda.NewHandler(handler).Invoke(ctx, payload)
 			return json.RawMessage(response), err
 		}
+		// a panicking handler skips End, the processor added to the
+		// provider of the user must not outlive the invocation anyway
+		defer tracer.unregisterProcessor()
 		tracer.Start()
 
 		response, lambdaErr := otellambda.WrapHandler(lambda.NewHandler(handler),
@@ -88,17 +104,91 @@ func newResource(ctx context.Context, extraAttrs ...attribute.KeyValue) *resourc
 	return r
 }
 
-// createExporter returns a console exporter.
-func createExporter(printStdout bool, ctx context.Context, logger log.FieldLogger) (trace.SpanExporter, error) {
-	if printStdout {
+// createExporters returns the exporters configured, skipping the ones
+// which fail to be created.
+func createExporters(conf Config, ctx context.Context, logger log.FieldLogger) ([]trace.SpanExporter, error) {
+	var exporters []trace.SpanExporter
+	for _, name := range conf.spansExporters() {
+		exporter, err := createSpansExporter(name, conf, ctx, logger)
+		if err != nil {
+			logger.WithError(err).Errorf("failed to create %s exporter", name)
+			continue
+		}
+		exporters = append(exporters, exporter)
+	}
+	for _, exporter := range conf.Exporters {
+		if exporter != nil {
+			exporters = append(exporters, batchedExporter{sharedExporter{exporter}})
+		}
+	}
+	if len(exporters) == 0 {
+		return nil, errors.New("no exporter created")
+	}
+	return exporters, nil
+}
+
+// SpansExporterFunc creates the exporter of a spans exporter registered
+// with RegisterSpansExporter, the exporter is kept across the invocations
+// and the tracer does not shut it down
+type SpansExporterFunc func(ctx context.Context) (trace.SpanExporter, error)
+
+var (
+	// spansExportersMu guards the registered spans exporters
+	spansExportersMu   sync.RWMutex
+	spansExporterFuncs = map[string]SpansExporterFunc{}
+)
+
+// RegisterSpansExporter makes name a spans exporter of SpansExporters and
+// LUMIGO_SPANS_EXPORTERS, created with newExporter on the invocations which
+// send to it. The opt-in exporter packages register theirs when imported
+func RegisterSpansExporter(name string, newExporter SpansExporterFunc) {
+	spansExportersMu.Lock()
+	defer spansExportersMu.Unlock()
+	spansExporterFuncs[name] = newExporter
+}
+
+// registeredSpansExporter returns the constructor of a registered spans
+// exporter, nil if there is none
+func registeredSpansExporter(name string) SpansExporterFunc {
+	spansExportersMu.RLock()
+	defer spansExportersMu.RUnlock()
+	return spansExporterFuncs[name]
+}
+
+// createSpansExporter returns a console exporter, an exporter to the
+// Lumigo edge, one writing span files for the extension or a registered one.
+func createSpansExporter(name string, conf Config, ctx context.Context, logger log.FieldLogger) (trace.SpanExporter, error) {
+	if newExporter := registeredSpansExporter(name); newExporter != nil {
+		exporter, err := newExporter(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return batchedExporter{sharedExporter{exporter}}, nil
+	}
+	switch name {
+	case StdoutSpansExporter:
 		return stdouttrace.New()
+	case EdgeSpansExporter:
+		return batchedExporter{newEdgeExporter(ctx, logger, conf.edgeURL(), conf.Token, conf.edgeTimeout())}, nil
 	}
-	if _, err := os.Stat(SPANS_DIR); errors.Is(err, os.ErrNotExist) {
-		if err := os.Mkdir(SPANS_DIR, os.ModePerm); err != nil {
-			return nil, errors.Wrapf(err, "failed to create dir: %s", SPANS_DIR)
+	dir := conf.spansDir()
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return nil, errors.Wrapf(err, "failed to create dir: %s", dir)
 		}
 	} else if err != nil {
 		logger.WithError(err).Error()
 	}
-	return newExporter(ctx, logger)
+	return newExporter(ctx, logger, dir, conf.spansQuota(), conf.spansCompression())
+}
+
+// sharedExporter an exporter of Config.Exporters, which lives across
+// the invocations, so it is not stopped with the processor of one
+type sharedExporter struct {
+	trace.SpanExporter
+}
+
+// Shutdown does not stop the exporter, its owner does
+func (e sharedExporter) Shutdown(ctx context.Context) error {
+	return nil
 }
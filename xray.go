@@ -0,0 +1,44 @@
+package lumigotracer
+
+import (
+	"context"
+	"os"
+
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTextMapPropagator the propagator of the trace context, the X-Ray
+// one maps the Root of X-Amzn-Trace-Id to the trace ID
+func newTextMapPropagator(propagateXRay bool) propagation.TextMapPropagator {
+	if !propagateXRay {
+		return propagation.TraceContext{}
+	}
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, xray.Propagator{})
+}
+
+// newIDGenerator generates trace IDs starting with their epoch time
+// as X-Ray requires, or random ones if nil
+func newIDGenerator(propagateXRay bool) sdktrace.IDGenerator {
+	if !propagateXRay {
+		return nil
+	}
+	return xray.NewIDGenerator()
+}
+
+// extractAmazonTraceContext returns ctx with the X-Ray trace of the
+// invocation from _X_AMZN_TRACE_ID as remote parent
+func extractAmazonTraceContext(ctx context.Context) context.Context {
+	header := os.Getenv("_X_AMZN_TRACE_ID")
+	if header == "" {
+		return ctx
+	}
+	carrier := propagation.MapCarrier{"X-Amzn-Trace-Id": header}
+	extracted := xray.Propagator{}.Extract(ctx, carrier)
+	if !trace.SpanContextFromContext(extracted).IsValid() {
+		return ctx
+	}
+	return extracted
+}
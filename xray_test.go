@@ -0,0 +1,64 @@
+package lumigotracer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTransportXRayPropagation(t *testing.T) {
+	var header http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+	}))
+	defer ts.Close()
+
+	sr := tracetest.NewSpanRecorder()
+	tr := NewTransport(http.DefaultTransport, WithPropagator(newTextMapPropagator(true)))
+	tr.provider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr), sdktrace.WithIDGenerator(newIDGenerator(true)))
+	c := http.Client{Transport: tr}
+
+	res, err := c.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, res.Body.Close())
+
+	spans := sr.Ended()
+	assert.Len(t, spans, 1)
+	sc := spans[0].SpanContext()
+	traceID := sc.TraceID().String()
+	assert.Equal(t, fmt.Sprintf("Root=1-%s-%s;Parent=%s;Sampled=1", traceID[:8], traceID[8:], sc.SpanID()), header.Get("X-Amzn-Trace-Id"))
+	assert.Equal(t, fmt.Sprintf("00-%s-%s-01", traceID, sc.SpanID()), header.Get("Traceparent"))
+}
+
+func TestTextMapPropagatorFields(t *testing.T) {
+	assert.ElementsMatch(t, []string{"traceparent", "tracestate"}, newTextMapPropagator(false).Fields())
+	assert.ElementsMatch(t, []string{"traceparent", "tracestate", "X-Amzn-Trace-Id"}, newTextMapPropagator(true).Fields())
+	assert.Nil(t, newIDGenerator(false))
+}
+
+func TestExtractAmazonTraceContext(t *testing.T) {
+	t.Setenv("_X_AMZN_TRACE_ID", "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1")
+	sc := trace.SpanContextFromContext(extractAmazonTraceContext(context.Background()))
+	assert.True(t, sc.IsRemote())
+	assert.Equal(t, "5759e988bd862e3fe1be46a994272793", sc.TraceID().String())
+	assert.Equal(t, "53995c3f42cd8ad8", sc.SpanID().String())
+	assert.True(t, sc.IsSampled())
+
+	// the same trace as seen by the W3C propagator
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(trace.ContextWithSpanContext(context.Background(), sc), carrier)
+	assert.Equal(t, "00-5759e988bd862e3fe1be46a994272793-53995c3f42cd8ad8-01", carrier.Get("traceparent"))
+
+	t.Setenv("_X_AMZN_TRACE_ID", "invalid")
+	assert.False(t, trace.SpanContextFromContext(extractAmazonTraceContext(context.Background())).IsValid())
+}